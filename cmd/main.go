@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
@@ -42,9 +43,18 @@ var rootCmd = &cobra.Command{
 
 var allPlugins = []plugins.IPlugin{
 	&plugins.ConfluencePlugin{},
+	&plugins.JiraPlugin{},
+	&plugins.TeamsPlugin{},
+	&plugins.GithubPlugin{},
+	&plugins.GitlabPlugin{},
+	&plugins.CiLogsPlugin{},
 	&plugins.DiscordPlugin{},
 	&plugins.FileSystemPlugin{},
+	&plugins.ImagePlugin{},
+	&plugins.KubernetesPlugin{},
+	&plugins.StdinPlugin{},
 	&plugins.SlackPlugin{},
+	&plugins.SlackExportPlugin{},
 	&plugins.PaligoPlugin{},
 	&plugins.GitPlugin{},
 }
@@ -147,6 +157,9 @@ func preRun(cmd *cobra.Command, args []string) {
 
 	secrets := secrets.Init(tags)
 
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+
 	go func() {
 		for {
 			select {
@@ -162,6 +175,10 @@ func preRun(cmd *cobra.Command, args []string) {
 					return
 				}
 				log.Fatal().Msg(err.Error())
+			case <-interrupts:
+				// the plugins are not stopped, the report is shown with the secrets found so far
+				log.Warn().Msg("Scan interrupted, the report contains partial results")
+				showReport(cmd)
 			}
 		}
 	}()
@@ -170,6 +187,14 @@ func preRun(cmd *cobra.Command, args []string) {
 func postRun(cmd *cobra.Command, args []string) {
 	channels.WaitGroup.Wait()
 
+	// Wait for last secret to be added to report
+	time.Sleep(time.Millisecond * timeSleepInterval)
+
+	showReport(cmd)
+}
+
+// showReport shows and writes the report, then exits with 1 if secrets were found
+func showReport(cmd *cobra.Command) {
 	reportPath, _ := cmd.Flags().GetStringSlice(reportPath)
 	stdoutFormat, _ := cmd.Flags().GetString(stdoutFormat)
 
@@ -177,9 +202,6 @@ func postRun(cmd *cobra.Command, args []string) {
 
 	cfg := config.LoadConfig("2ms", Version)
 
-	// Wait for last secret to be added to report
-	time.Sleep(time.Millisecond * timeSleepInterval)
-
 	// -------------------------------------
 	// Show Report
 	if report.TotalItemsScanned > 0 {
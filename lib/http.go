@@ -1,12 +1,20 @@
 package lib
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
+// MaxHttpRetries is the number of times a rate limited request is retried before giving up
+const MaxHttpRetries = 5
+
 type ICredentials interface {
 	GetCredentials() (string, string)
 }
@@ -17,7 +25,8 @@ func CreateBasicAuthCredentials(credentials ICredentials) string {
 }
 
 type IAuthorizationHeader interface {
-	GetAuthorizationHeader() string
+	// GetAuthorizationHeader returns the Authorization header value, or an empty string for anonymous requests
+	GetAuthorizationHeader() (string, error)
 }
 
 func HttpRequest(method string, url string, autherization IAuthorizationHeader) ([]byte, *http.Response, error) {
@@ -26,22 +35,38 @@ func HttpRequest(method string, url string, autherization IAuthorizationHeader)
 		return nil, nil, fmt.Errorf("unexpected error creating an http request %w", err)
 	}
 
-	if autherization.GetAuthorizationHeader() != "" {
-		request.Header.Set("Authorization", autherization.GetAuthorizationHeader())
+	return sendHttpRequest(request, autherization)
+}
+
+// HttpJsonRequest sends the given JSON body, for APIs like GraphQL that are queried with POST requests
+func HttpJsonRequest(method string, url string, body []byte, autherization IAuthorizationHeader) ([]byte, *http.Response, error) {
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected error creating an http request %w", err)
 	}
+	request.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	response, err := client.Do(request)
+	return sendHttpRequest(request, autherization)
+}
+
+// HttpStreamRequest returns the response of a successful request without reading its body, the caller must close it
+func HttpStreamRequest(method string, url string, autherization IAuthorizationHeader) (*http.Response, error) {
+	request, err := http.NewRequest(method, url, nil)
 	if err != nil {
-		return nil, response, fmt.Errorf("unable to send http request %w", err)
+		return nil, fmt.Errorf("unexpected error creating an http request %w", err)
 	}
 
-	defer response.Body.Close()
+	return doHttpRequest(request, autherization)
+}
 
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		return nil, response, fmt.Errorf("error calling http url \"%v\". status code: %v", url, response)
+func sendHttpRequest(request *http.Request, autherization IAuthorizationHeader) ([]byte, *http.Response, error) {
+	response, err := doHttpRequest(request, autherization)
+	if err != nil {
+		return nil, response, err
 	}
 
+	defer response.Body.Close()
+
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
 		return nil, response, fmt.Errorf("unexpected error reading http response body %w", err)
@@ -49,3 +74,65 @@ func HttpRequest(method string, url string, autherization IAuthorizationHeader)
 
 	return body, response, nil
 }
+
+func doHttpRequest(request *http.Request, autherization IAuthorizationHeader) (*http.Response, error) {
+	url := request.URL.String()
+
+	header, err := autherization.GetAuthorizationHeader()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the authorization header %w", err)
+	}
+	if header != "" {
+		request.Header.Set("Authorization", header)
+	}
+
+	client := &http.Client{}
+	response, err := client.Do(request)
+	if err != nil {
+		return response, fmt.Errorf("unable to send http request %w", err)
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		response.Body.Close()
+		return response, fmt.Errorf("error calling http url \"%v\". status code: %v", url, response)
+	}
+
+	return response, nil
+}
+
+// RetryDelay returns how long to wait before retrying the request of the given failed response, or false when it must not be retried
+type RetryDelay func(response *http.Response, attempt int) (time.Duration, bool)
+
+// RetryAfterDelay retries the requests rejected with 429 Too Many Requests, after the Retry-After delay or an exponential backoff
+func RetryAfterDelay(response *http.Response, attempt int) (time.Duration, bool) {
+	if response.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		return time.Second * time.Duration(seconds), true
+	}
+	return time.Second * time.Duration(1<<attempt), true
+}
+
+// RetryHttpRequest calls send until it succeeds, the failure is not retried by retryDelay or MaxHttpRetries is reached
+func RetryHttpRequest(send func() ([]byte, *http.Response, error), retryDelay RetryDelay) ([]byte, *http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		body, response, err := send()
+		if err == nil {
+			return body, response, nil
+		}
+		if response == nil {
+			return nil, nil, err
+		}
+		delay, retry := retryDelay(response, attempt)
+		if !retry {
+			return nil, response, err
+		}
+		if attempt >= MaxHttpRetries {
+			return nil, response, fmt.Errorf("giving up after %d retries: %w", MaxHttpRetries, err)
+		}
+
+		log.Warn().Msgf("Rate limit exceeded, retrying in %s", delay)
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type failingAuthorization struct{}
+
+func (failingAuthorization) GetAuthorizationHeader() (string, error) {
+	return "", fmt.Errorf("token expired")
+}
+
+type anonymousAuthorization struct{}
+
+func (anonymousAuthorization) GetAuthorizationHeader() (string, error) {
+	return "", nil
+}
+
+func TestHttpRequestFailsWithoutAuthorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected unauthenticated request: %s", r.URL.String())
+	}))
+	defer server.Close()
+
+	_, _, err := HttpRequest(http.MethodGet, server.URL, failingAuthorization{})
+	if err == nil || !strings.Contains(err.Error(), "token expired") {
+		t.Errorf("expected authorization error, but got %v", err)
+	}
+}
+
+func TestRetryHttpRequest(t *testing.T) {
+	tests := []struct {
+		name             string
+		status           int
+		failures         int
+		expectedRequests int
+		expectedError    bool
+	}{
+		{
+			name:             "succeeds after rate limits",
+			status:           http.StatusTooManyRequests,
+			failures:         2,
+			expectedRequests: 3,
+		},
+		{
+			name:             "gives up after max retries",
+			status:           http.StatusTooManyRequests,
+			failures:         MaxHttpRetries + 10,
+			expectedRequests: MaxHttpRetries + 1,
+			expectedError:    true,
+		},
+		{
+			name:             "other errors are not retried",
+			status:           http.StatusNotFound,
+			failures:         1,
+			expectedRequests: 1,
+			expectedError:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests <= tt.failures {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.status)
+					return
+				}
+				fmt.Fprint(w, "ok")
+			}))
+			defer server.Close()
+
+			body, _, err := RetryHttpRequest(func() ([]byte, *http.Response, error) {
+				return HttpRequest(http.MethodGet, server.URL, anonymousAuthorization{})
+			}, RetryAfterDelay)
+
+			if tt.expectedError != (err != nil) {
+				t.Errorf("expected error %v, but got %v", tt.expectedError, err)
+			}
+			if !tt.expectedError && string(body) != "ok" {
+				t.Errorf("expected body ok, but got %q", body)
+			}
+			if requests != tt.expectedRequests {
+				t.Errorf("expected %d requests, but got %d", tt.expectedRequests, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthTypeBasic  = "basic"
+	AuthTypeBearer = "bearer"
+	AuthTypeOAuth2 = "oauth2"
+)
+
+// Renew the access token a bit before it expires, so it is not rejected during a request
+const tokenExpiryDelta = 30 * time.Second
+
+func CreateBearerAuthCredentials(token string) string {
+	return "Bearer " + token
+}
+
+// OAuthClientCredentials fetches and caches an OAuth 2.0 access token using the client credentials grant
+type OAuthClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	mutex       sync.Mutex
+	accessToken string
+	expiry      time.Time
+}
+
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+func NewOAuthClientCredentials(tokenUrl string, clientId string, clientSecret string, scopes []string) *OAuthClientCredentials {
+	return &OAuthClientCredentials{
+		TokenURL:     tokenUrl,
+		ClientID:     clientId,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+	}
+}
+
+// GetToken returns the cached access token, or requests a new one if there is none or it is about to expire
+func (c *OAuthClientCredentials) GetToken() (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.accessToken != "" && (c.expiry.IsZero() || time.Now().Before(c.expiry)) {
+		return c.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.ClientID)
+	form.Set("client_secret", c.ClientSecret)
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	request, err := http.NewRequest(http.MethodPost, c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("unexpected error creating an oauth token request %w", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	client := &http.Client{}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("unable to send oauth token request %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", fmt.Errorf("unexpected error reading oauth token response body %w", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", fmt.Errorf("error requesting oauth token from \"%v\". status code: %v", c.TokenURL, response.StatusCode)
+	}
+
+	token := oauthTokenResponse{}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("could not unmarshal oauth token response %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("oauth token response from \"%v\" has no access token", c.TokenURL)
+	}
+
+	c.accessToken = token.AccessToken
+	c.expiry = time.Time{}
+	if token.ExpiresIn > 0 {
+		c.expiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+
+	return c.accessToken, nil
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOAuthClientCredentialsGetToken(t *testing.T) {
+	tests := []struct {
+		name             string
+		expiresIn        int
+		expectedRequests int
+	}{
+		{
+			name:             "cached until expiry",
+			expiresIn:        3600,
+			expectedRequests: 1,
+		},
+		{
+			name:             "cached without expiry",
+			expiresIn:        0,
+			expectedRequests: 1,
+		},
+		{
+			name:             "renewed before expiry",
+			expiresIn:        10,
+			expectedRequests: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if err := r.ParseForm(); err != nil {
+					t.Fatal(err)
+				}
+				if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "read write" {
+					t.Errorf("unexpected token request: %v", r.Form)
+				}
+				fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, requests, tt.expiresIn)
+			}))
+			defer server.Close()
+
+			oauth := NewOAuthClientCredentials(server.URL, "id", "secret", []string{"read", "write"})
+			var token string
+			for i := 0; i < 2; i++ {
+				var err error
+				if token, err = oauth.GetToken(); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			if requests != tt.expectedRequests {
+				t.Errorf("expected %d token requests, but got %d", tt.expectedRequests, requests)
+			}
+			if expected := fmt.Sprintf("token-%d", tt.expectedRequests); token != expected {
+				t.Errorf("expected token %s, but got %s", expected, token)
+			}
+		})
+	}
+}
+
+func TestOAuthClientCredentialsGetTokenErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		response      string
+		expectedError string
+	}{
+		{
+			name:          "rejected credentials",
+			status:        http.StatusUnauthorized,
+			response:      `{"error": "invalid_client"}`,
+			expectedError: "status code: 401",
+		},
+		{
+			name:          "no access token",
+			status:        http.StatusOK,
+			response:      `{"token_type": "Bearer"}`,
+			expectedError: "has no access token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer server.Close()
+
+			_, err := NewOAuthClientCredentials(server.URL, "id", "secret", nil).GetToken()
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error containing %q, but got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
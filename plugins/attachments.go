@@ -0,0 +1,193 @@
+package plugins
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachments bigger than this size are skipped
+const maxAttachmentSize = 10 * 1024 * 1024
+
+// Number of leading bytes inspected when guessing if unknown content is text
+const textSniffSize = 8000
+
+var textAttachmentExtensions = []string{
+	".txt", ".text", ".env", ".log", ".md", ".csv", ".tsv",
+	".json", ".yaml", ".yml", ".xml", ".toml", ".ini", ".cfg", ".conf", ".config", ".properties",
+	".sh", ".bash", ".zsh", ".ps1", ".bat", ".cmd",
+	".py", ".js", ".ts", ".go", ".java", ".cs", ".rb", ".php", ".sql", ".tf", ".tfvars",
+	".pem", ".key", ".crt", ".cer", ".pub", ".html", ".htm",
+}
+
+var textAttachmentMediaTypes = []string{
+	"application/json", "application/xml", "application/x-yaml", "application/yaml",
+	"application/x-sh", "application/javascript", "application/x-pem-file", "application/sql",
+}
+
+var officeAttachmentParts = map[string][]string{
+	".docx": {"word/document.xml", "word/header", "word/footer", "word/footnotes.xml", "word/comments.xml"},
+	".xlsx": {"xl/sharedStrings.xml"},
+	".pptx": {"ppt/slides/slide", "ppt/notesSlides/notesSlide"},
+}
+
+var errUnsupportedAttachment = fmt.Errorf("unsupported attachment type")
+
+// getAttachmentContent returns the text content of an attachment, based on its file name, media type and data.
+// Plain text files are returned as is, the text of Office documents (docx, xlsx, pptx) and PDF files is extracted.
+func getAttachmentContent(fileName string, mediaType string, data []byte) (string, error) {
+	extension := strings.ToLower(filepath.Ext(fileName))
+	mediaType = strings.ToLower(mediaType)
+
+	if _, ok := officeAttachmentParts[extension]; ok {
+		return getOfficeContent(extension, data)
+	}
+	if extension == ".pdf" || mediaType == "application/pdf" {
+		return getPdfContent(data)
+	}
+	if isTextAttachment(extension, mediaType, data) {
+		return string(data), nil
+	}
+
+	return "", errUnsupportedAttachment
+}
+
+func isTextAttachment(extension string, mediaType string, data []byte) bool {
+	for _, textExtension := range textAttachmentExtensions {
+		if extension == textExtension {
+			return true
+		}
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	for _, textMediaType := range textAttachmentMediaTypes {
+		if mediaType == textMediaType {
+			return true
+		}
+	}
+
+	sample := data
+	if len(sample) > textSniffSize {
+		sample = trimPartialRune(sample[:textSniffSize])
+	}
+	return len(sample) > 0 && bytes.IndexByte(sample, 0) == -1 && utf8.Valid(sample)
+}
+
+// trimPartialRune drops a multi-byte character cut at the end of the sample
+func trimPartialRune(sample []byte) []byte {
+	for i := len(sample) - 1; i >= 0 && i >= len(sample)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(sample[i]) {
+			if !utf8.FullRune(sample[i:]) {
+				return sample[:i]
+			}
+			break
+		}
+	}
+	return sample
+}
+
+func getOfficeContent(extension string, data []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("error while opening %s document: %w", extension, err)
+	}
+
+	var content strings.Builder
+	for _, file := range reader.File {
+		if !isOfficeTextPart(extension, file.Name) {
+			continue
+		}
+		part, err := file.Open()
+		if err != nil {
+			return "", fmt.Errorf("error while reading %s: %w", file.Name, err)
+		}
+		err = writeXmlText(&content, part)
+		part.Close()
+		if err != nil {
+			return "", fmt.Errorf("error while parsing %s: %w", file.Name, err)
+		}
+	}
+
+	return content.String(), nil
+}
+
+func isOfficeTextPart(extension string, partName string) bool {
+	if !strings.HasSuffix(partName, ".xml") {
+		return false
+	}
+	for _, prefix := range officeAttachmentParts[extension] {
+		if strings.HasPrefix(partName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeXmlText writes the character data of an Office XML part, one line per paragraph, cell or row
+func writeXmlText(content *strings.Builder, reader io.Reader) error {
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.CharData:
+			content.Write(element)
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "p", "si", "row", "br":
+				content.WriteString("\n")
+			}
+		}
+	}
+}
+
+var (
+	pdfStreamRegex = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	pdfTextRegex   = regexp.MustCompile(`(?s)\(((?:\\.|[^\\)])*)\)`)
+	pdfLineRegex   = regexp.MustCompile(`(?s)\bBT\b(.*?)\bET\b`)
+)
+
+// getPdfContent is a best effort extraction of the literal strings drawn by the PDF text operators.
+// Text encoded with custom font mappings (CID fonts) can not be recovered this way.
+func getPdfContent(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		return "", fmt.Errorf("invalid pdf document")
+	}
+
+	var content strings.Builder
+	for _, stream := range pdfStreamRegex.FindAllSubmatch(data, -1) {
+		raw := stream[1]
+		if reader, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			if inflated, err := io.ReadAll(reader); err == nil {
+				raw = inflated
+			}
+			reader.Close()
+		}
+		for _, block := range pdfLineRegex.FindAllSubmatch(raw, -1) {
+			for _, text := range pdfTextRegex.FindAllSubmatch(block[1], -1) {
+				content.WriteString(unescapePdfString(text[1]))
+			}
+			content.WriteString("\n")
+		}
+	}
+
+	return content.String(), nil
+}
+
+func unescapePdfString(text []byte) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\(`, "(", `\)`, ")", `\\`, `\`)
+	return replacer.Replace(string(text))
+}
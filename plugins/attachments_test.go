@@ -0,0 +1,99 @@
+package plugins
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+)
+
+func createZip(t *testing.T, files map[string]string) []byte {
+	buffer := &bytes.Buffer{}
+	writer := zip.NewWriter(buffer)
+	for name, content := range files {
+		file, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestGetAttachmentContent(t *testing.T) {
+	docx := createZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>first line</w:t></w:r></w:p><w:p><w:r><w:t>password=</w:t></w:r><w:r><w:t>secret</w:t></w:r></w:p></w:body></w:document>`,
+		"word/styles.xml":   `<w:styles xmlns:w="w"><w:p><w:t>ignored</w:t></w:p></w:styles>`,
+	})
+
+	tests := []struct {
+		name            string
+		fileName        string
+		mediaType       string
+		data            []byte
+		expectedContent string
+		expectedError   bool
+	}{
+		{
+			name:            "env file",
+			fileName:        "prod.env",
+			mediaType:       "application/octet-stream",
+			data:            []byte("AWS_SECRET_ACCESS_KEY=abc"),
+			expectedContent: "AWS_SECRET_ACCESS_KEY=abc",
+		},
+		{
+			name:            "text media type",
+			fileName:        "notes",
+			mediaType:       "text/plain",
+			data:            []byte("token: 123"),
+			expectedContent: "token: 123",
+		},
+		{
+			name:            "unknown extension with text content",
+			fileName:        "config.unknown",
+			data:            []byte("key = value\n"),
+			expectedContent: "key = value\n",
+		},
+		{
+			name:          "binary content",
+			fileName:      "image.png",
+			mediaType:     "image/png",
+			data:          []byte{0x89, 'P', 'N', 'G', 0x00, 0x01},
+			expectedError: true,
+		},
+		{
+			name:            "word document",
+			fileName:        "runbook.docx",
+			data:            docx,
+			expectedContent: "first line\npassword=secret\n",
+		},
+		{
+			name:            "pdf document",
+			fileName:        "manual.pdf",
+			data:            []byte("%PDF-1.4\n1 0 obj\n<< /Length 44 >>\nstream\nBT /F1 12 Tf (api_key = \\(123\\)) Tj ET\nendstream\nendobj\n"),
+			expectedContent: "api_key = (123)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := getAttachmentContent(tt.fileName, tt.mediaType, tt.data)
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if content != tt.expectedContent {
+				t.Errorf("expected content %q, but got %q", tt.expectedContent, content)
+			}
+		})
+	}
+}
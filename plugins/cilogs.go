@@ -0,0 +1,452 @@
+package plugins
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ciProviderFlag = "provider"
+	ciBaseUrlFlag  = "base-url"
+	ciRepoFlag     = "repo"
+	ciUsernameFlag = "username"
+	ciTokenFlag    = "token"
+	ciSinceFlag    = "since"
+	ciUntilFlag    = "until"
+)
+
+const (
+	ciProviderGithub  = "github"
+	ciProviderGitlab  = "gitlab"
+	ciProviderJenkins = "jenkins"
+)
+
+const (
+	ciDateFormat       = "2006-01-02"
+	ciDefaultDateRange = time.Hour * 24 * 7
+	ciMaxLogRequests   = 5
+	ciMaxLogSize       = 50 * 1024 * 1024
+	ciProviderKey      = "provider"
+	ciJobKey           = "job"
+)
+
+// Colors and formatting sequences written by the CI runners, they may split the secrets
+var ansiEscapeRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]`)
+
+type CiLogsPlugin struct {
+	Plugin
+	Channels
+
+	Provider string
+	BaseURL  string
+	Repo     string
+	Username string
+	Token    string
+	Since    string
+	Until    string
+
+	since time.Time
+	until time.Time
+}
+
+func (p *CiLogsPlugin) GetName() string {
+	return "ci-logs"
+}
+
+func (p *CiLogsPlugin) GetCredentials() (string, string) {
+	return p.Username, p.Token
+}
+
+func (p *CiLogsPlugin) GetAuthorizationHeader() (string, error) {
+	if p.Token == "" {
+		return "", nil
+	}
+	if p.Username != "" {
+		return lib.CreateBasicAuthCredentials(p), nil
+	}
+	return lib.CreateBearerAuthCredentials(p.Token), nil
+}
+
+func (p *CiLogsPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s PROVIDER --%s REPO", p.GetName(), ciProviderFlag, ciRepoFlag),
+		Short: "Scan CI job logs",
+		Long:  "Scan the logs of the CI jobs (GitHub Actions runs, GitLab CI jobs or Jenkins builds) started in a date range for sensitive information",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.initialize(); err != nil {
+				p.Errors <- fmt.Errorf("error while initializing ci-logs plugin: %w", err)
+				return
+			}
+			log.Info().Msg("CI logs plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Provider, ciProviderFlag, "", fmt.Sprintf("CI provider (%s, %s, %s) [required]", ciProviderGithub, ciProviderGitlab, ciProviderJenkins))
+	flags.StringVar(&p.Repo, ciRepoFlag, "", "GitHub repository (OWNER/REPO), GitLab project (ID or full path) or Jenkins job (example: folder/job) [required]")
+	flags.StringVar(&p.BaseURL, ciBaseUrlFlag, "", fmt.Sprintf("API base URL, required for Jenkins (default %s for GitHub, %s for GitLab)", githubDefaultBaseUrl, gitlabDefaultBaseUrl))
+	flags.StringVar(&p.Username, ciUsernameFlag, "", "Jenkins user name, the token is then used as the user API token")
+	flags.StringVar(&p.Token, ciTokenFlag, "", "GitHub or GitLab access token, or Jenkins API token")
+	flags.StringVar(&p.Since, ciSinceFlag, "", "Scan the jobs started from this date, in the YYYY-MM-DD format (default 7 days ago)")
+	flags.StringVar(&p.Until, ciUntilFlag, "", "Scan the jobs started until this date included, in the YYYY-MM-DD format (default today)")
+	for _, flag := range []string{ciProviderFlag, ciRepoFlag} {
+		if err := command.MarkFlagRequired(flag); err != nil {
+			return nil, fmt.Errorf("error while marking flag %s as required: %w", flag, err)
+		}
+	}
+
+	return command, nil
+}
+
+func (p *CiLogsPlugin) initialize() error {
+	p.Provider = strings.ToLower(p.Provider)
+	p.BaseURL = strings.TrimRight(p.BaseURL, "/")
+	switch p.Provider {
+	case ciProviderGithub:
+		if parts := strings.Split(p.Repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid repository %s, expected format is OWNER/REPO", p.Repo)
+		}
+	case ciProviderGitlab:
+	case ciProviderJenkins:
+		if p.BaseURL == "" {
+			return fmt.Errorf("'%s' flag is required for the %s provider", ciBaseUrlFlag, ciProviderJenkins)
+		}
+	default:
+		return fmt.Errorf("invalid provider: %s, available providers are: %s, %s and %s", p.Provider, ciProviderGithub, ciProviderGitlab, ciProviderJenkins)
+	}
+
+	var err error
+	p.since = timeNow.Add(-ciDefaultDateRange).Truncate(time.Hour * 24)
+	if p.Since != "" {
+		if p.since, err = time.Parse(ciDateFormat, p.Since); err != nil {
+			return fmt.Errorf("invalid '%s' date: %w", ciSinceFlag, err)
+		}
+	}
+	p.until = timeNow
+	if p.Until != "" {
+		if p.until, err = time.Parse(ciDateFormat, p.Until); err != nil {
+			return fmt.Errorf("invalid '%s' date: %w", ciUntilFlag, err)
+		}
+		p.until = p.until.Add(time.Hour*24 - time.Nanosecond)
+	}
+	if p.until.Before(p.since) {
+		return fmt.Errorf("'%s' date is after the '%s' date", ciSinceFlag, ciUntilFlag)
+	}
+
+	p.Limit = make(chan struct{}, ciMaxLogRequests)
+	return nil
+}
+
+func (p *CiLogsPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		var err error
+		switch p.Provider {
+		case ciProviderGithub:
+			err = p.getGithubItems()
+		case ciProviderGitlab:
+			err = p.getGitlabItems()
+		case ciProviderJenkins:
+			err = p.getJenkinsItems()
+		}
+		if err != nil {
+			p.Errors <- err
+		}
+	}()
+}
+
+// scanLog downloads and sends the log in a goroutine limited by the number of concurrent downloads
+func (p *CiLogsPlugin) scanLog(sendItems func() error) {
+	p.WaitGroup.Add(1)
+	p.Limit <- struct{}{}
+	go func() {
+		defer p.WaitGroup.Done()
+		defer func() { <-p.Limit }()
+
+		if err := sendItems(); err != nil {
+			p.Errors <- err
+		}
+	}()
+}
+
+func (p *CiLogsPlugin) sendLogItem(id string, job string, content []byte) {
+	p.Items <- Item{
+		Content:  ansiEscapeRegex.ReplaceAllString(string(content), ""),
+		ID:       id,
+		Metadata: map[string]string{ciProviderKey: p.Provider, ciJobKey: job},
+	}
+}
+
+// readLog reads at most ciMaxLogSize bytes of the log, the rest is not scanned
+func readLog(reader io.Reader, id string) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(reader, ciMaxLogSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > ciMaxLogSize {
+		log.Warn().Msgf("Log %s exceeds %d bytes, only the beginning is scanned", id, ciMaxLogSize)
+		content = content[:ciMaxLogSize]
+	}
+	return content, nil
+}
+
+// ciApiClient sends the requests of the CI provider API, the plugins of the providers are not needed to read the logs
+type ciApiClient struct {
+	authorization lib.IAuthorizationHeader
+	retryDelay    lib.RetryDelay
+}
+
+func (c *ciApiClient) request(url string) ([]byte, *http.Response, error) {
+	return lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		return lib.HttpRequest(http.MethodGet, url, c.authorization)
+	}, c.retryDelay)
+}
+
+// download returns the response of the url with its open body, the caller must close it
+func (c *ciApiClient) download(url string) (*http.Response, error) {
+	var response *http.Response
+	_, failedResponse, err := lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		var err error
+		response, err = lib.HttpStreamRequest(http.MethodGet, url, c.authorization)
+		return nil, response, err
+	}, c.retryDelay)
+	if err != nil {
+		return failedResponse, err
+	}
+	return response, nil
+}
+
+func (p *CiLogsPlugin) getGithubItems() error {
+	baseUrl := githubDefaultBaseUrl
+	if p.BaseURL != "" {
+		baseUrl = p.BaseURL
+	}
+	client := &ciApiClient{authorization: p, retryDelay: getGithubRetryDelay}
+
+	runsUrl := fmt.Sprintf("%s/repos/%s/actions/runs?created=%s..%s&per_page=%d",
+		baseUrl, p.Repo, p.since.Format(ciDateFormat), p.until.Format(ciDateFormat), githubPageSize)
+	for runsUrl != "" {
+		body, response, err := client.request(runsUrl)
+		if err != nil {
+			return fmt.Errorf("unexpected error getting workflow runs of repository %s: %w", p.Repo, err)
+		}
+		runs := GithubWorkflowRuns{}
+		if err := json.Unmarshal(body, &runs); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+
+		for _, run := range runs.WorkflowRuns {
+			run := run
+			p.scanLog(func() error {
+				logsUrl := fmt.Sprintf("%s/repos/%s/actions/runs/%d/logs", baseUrl, p.Repo, run.ID)
+				response, err := client.download(logsUrl)
+				if err != nil {
+					if isUnavailableLog(response) {
+						log.Warn().Msgf("Skipping unavailable logs of workflow run %s: %s", run.HtmlUrl, err)
+						return nil
+					}
+					return fmt.Errorf("unexpected error downloading logs of workflow run %s: %w", run.HtmlUrl, err)
+				}
+				defer response.Body.Close()
+				return p.sendGithubRunLogItems(run, response.Body)
+			})
+		}
+		runsUrl = getGithubNextLink(response.Header.Get("Link"))
+	}
+	return nil
+}
+
+// sendGithubRunLogItems reads the logs archive of a run. It contains a log file for each job at its root,
+// and a folder for each job with the same log split by steps, which is only read if the job log is missing.
+// The archive is written to a temporary file, so the logs are read one by one instead of all in memory.
+func (p *CiLogsPlugin) sendGithubRunLogItems(run GithubWorkflowRun, archive io.Reader) error {
+	archiveFile, err := os.CreateTemp("", "2ms-ci-logs-*.zip")
+	if err != nil {
+		return fmt.Errorf("error while creating logs archive file: %w", err)
+	}
+	defer os.Remove(archiveFile.Name())
+	defer archiveFile.Close()
+
+	size, err := io.Copy(archiveFile, archive)
+	if err != nil {
+		return fmt.Errorf("error while downloading logs archive of workflow run %s: %w", run.HtmlUrl, err)
+	}
+	reader, err := zip.NewReader(archiveFile, size)
+	if err != nil {
+		return fmt.Errorf("error while reading logs archive of workflow run %s: %w", run.HtmlUrl, err)
+	}
+
+	hasJobLogs := false
+	for _, file := range reader.File {
+		if !file.FileInfo().IsDir() && !strings.Contains(file.Name, "/") {
+			hasJobLogs = true
+		}
+	}
+
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() || (hasJobLogs && strings.Contains(file.Name, "/")) {
+			continue
+		}
+		id := fmt.Sprintf("%s; Log: %s", run.HtmlUrl, file.Name)
+		content, err := readZipFile(file, id)
+		if err != nil {
+			return fmt.Errorf("error while reading log %s of workflow run %s: %w", file.Name, run.HtmlUrl, err)
+		}
+		p.sendLogItem(id, run.Name, content)
+	}
+	return nil
+}
+
+func readZipFile(file *zip.File, id string) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLog(reader, id)
+}
+
+func (p *CiLogsPlugin) getGitlabItems() error {
+	baseUrl := gitlabDefaultBaseUrl
+	if p.BaseURL != "" {
+		baseUrl = p.BaseURL
+	}
+	client := &ciApiClient{authorization: p, retryDelay: lib.RetryAfterDelay}
+	projectUrl := fmt.Sprintf("%s/api/v4/projects/%s", baseUrl, url.PathEscape(p.Repo))
+
+	// the jobs are listed from the most recent and cannot be filtered by date
+	for page := "1"; page != ""; {
+		body, response, err := client.request(fmt.Sprintf("%s/jobs?per_page=%d&page=%s", projectUrl, gitlabPageSize, page))
+		if err != nil {
+			return fmt.Errorf("unexpected error getting jobs of project %s: %w", p.Repo, err)
+		}
+		jobs := []GitlabJob{}
+		if err := json.Unmarshal(body, &jobs); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+
+		for _, job := range jobs {
+			if job.CreatedAt.Before(p.since) {
+				return nil
+			}
+			if job.CreatedAt.After(p.until) {
+				continue
+			}
+			job := job
+			p.scanLog(func() error {
+				return p.downloadLog(client, fmt.Sprintf("%s/jobs/%d/trace", projectUrl, job.ID), job.WebUrl, job.Name)
+			})
+		}
+		page = response.Header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+func (p *CiLogsPlugin) getJenkinsItems() error {
+	jobUrl := p.BaseURL
+	for _, name := range strings.Split(strings.Trim(p.Repo, "/"), "/") {
+		jobUrl += "/job/" + url.PathEscape(name)
+	}
+	client := &ciApiClient{authorization: p, retryDelay: lib.RetryAfterDelay}
+
+	body, _, err := client.request(jobUrl + "/api/json?tree=allBuilds[number,url,timestamp,fullDisplayName]")
+	if err != nil {
+		return fmt.Errorf("unexpected error getting builds of job %s: %w", p.Repo, err)
+	}
+	job := JenkinsJob{}
+	if err := json.Unmarshal(body, &job); err != nil {
+		return fmt.Errorf("could not unmarshal response %w", err)
+	}
+
+	for _, build := range job.AllBuilds {
+		started := time.UnixMilli(build.Timestamp)
+		if started.Before(p.since) || started.After(p.until) {
+			continue
+		}
+		build := build
+		p.scanLog(func() error {
+			consoleUrl := strings.TrimRight(build.Url, "/") + "/consoleText"
+			return p.downloadLog(client, consoleUrl, consoleUrl, build.FullDisplayName)
+		})
+	}
+	return nil
+}
+
+// downloadLog sends the log of a job, a GitLab trace or a Jenkins console output
+func (p *CiLogsPlugin) downloadLog(client *ciApiClient, logUrl string, id string, job string) error {
+	response, err := client.download(logUrl)
+	if err != nil {
+		if isUnavailableLog(response) {
+			log.Warn().Msgf("Skipping unavailable log of job %s: %s", id, err)
+			return nil
+		}
+		return fmt.Errorf("unexpected error downloading log of job %s: %w", id, err)
+	}
+	defer response.Body.Close()
+
+	content, err := readLog(response.Body, id)
+	if err != nil {
+		return fmt.Errorf("unexpected error downloading log of job %s: %w", id, err)
+	}
+	p.sendLogItem(id, job, content)
+	return nil
+}
+
+// isUnavailableLog reports whether the log was deleted, expired after the retention period or cannot be read
+// with the permissions of the token. These logs are skipped, the other errors stop the scan.
+func isUnavailableLog(response *http.Response) bool {
+	if response == nil {
+		return false
+	}
+	switch response.StatusCode {
+	case http.StatusForbidden, http.StatusNotFound, http.StatusGone:
+		return true
+	}
+	return false
+}
+
+type GithubWorkflowRuns struct {
+	TotalCount   int                 `json:"total_count"`
+	WorkflowRuns []GithubWorkflowRun `json:"workflow_runs"`
+}
+
+type GithubWorkflowRun struct {
+	ID      int64  `json:"id"`
+	Name    string `json:"name"`
+	HtmlUrl string `json:"html_url"`
+}
+
+type GitlabJob struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	WebUrl    string    `json:"web_url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JenkinsJob struct {
+	AllBuilds []JenkinsBuild `json:"allBuilds"`
+}
+
+type JenkinsBuild struct {
+	Number          int    `json:"number"`
+	Url             string `json:"url"`
+	Timestamp       int64  `json:"timestamp"`
+	FullDisplayName string `json:"fullDisplayName"`
+}
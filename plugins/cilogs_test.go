@@ -0,0 +1,197 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCiLogsInitializeDates(t *testing.T) {
+	p := &CiLogsPlugin{Provider: "GitLab", Repo: "group/app", Since: "2023-05-01", Until: "2023-05-31"}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.since != time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC) {
+		t.Errorf("unexpected since date %v", p.since)
+	}
+	if !p.until.After(time.Date(2023, 5, 31, 23, 59, 0, 0, time.UTC)) || !p.until.Before(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the until date to include the whole day, but got %v", p.until)
+	}
+
+	p = &CiLogsPlugin{Provider: "gitlab", Repo: "group/app", Since: "2023-05-31", Until: "2023-05-01"}
+	if err := p.initialize(); err == nil {
+		t.Error("expected error for since date after until date")
+	}
+	p = &CiLogsPlugin{Provider: "jenkins", Repo: "job"}
+	if err := p.initialize(); err == nil {
+		t.Error("expected error for jenkins without base url")
+	}
+}
+
+func TestReadLogLimit(t *testing.T) {
+	log := io.MultiReader(strings.NewReader("TOKEN=123"), bytes.NewReader(make([]byte, ciMaxLogSize)))
+
+	content, err := readLog(log, "job")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(content) != ciMaxLogSize || !bytes.HasPrefix(content, []byte("TOKEN=123")) {
+		t.Errorf("expected the first %d bytes of the log, but got %d bytes", ciMaxLogSize, len(content))
+	}
+}
+
+func scanCiLogs(t *testing.T, p *CiLogsPlugin) []Item {
+	p.Channels = Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	// logs are downloaded concurrently
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func assertCiLogItems(t *testing.T, items []Item, expected []Item) {
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID || item.Metadata[ciJobKey] != expected[i].Metadata[ciJobKey] {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+	}
+}
+
+func TestCiLogsGithub(t *testing.T) {
+	logs := createZip(t, map[string]string{
+		"0_build.txt":            "2023-05-02T10:00:00Z echo \x1b[32mTOKEN=123\x1b[0m",
+		"build/1_Set up job.txt": "2023-05-02T10:00:00Z echo TOKEN=123",
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/octo/app/actions/runs":
+			if r.URL.Query().Get("created") != "2023-05-01..2023-05-31" {
+				t.Errorf("unexpected created filter %s", r.URL.Query().Get("created"))
+			}
+			_, _ = w.Write([]byte(`{"total_count": 2, "workflow_runs": [
+				{"id": 1, "name": "CI", "html_url": "https://github.com/octo/app/actions/runs/1"},
+				{"id": 2, "name": "Release", "html_url": "https://github.com/octo/app/actions/runs/2"}
+			]}`))
+		case "/repos/octo/app/actions/runs/1/logs":
+			http.Redirect(w, r, "/blob/logs.zip", http.StatusFound)
+		case "/repos/octo/app/actions/runs/2/logs":
+			w.WriteHeader(http.StatusGone)
+		case "/blob/logs.zip":
+			_, _ = w.Write(logs)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	items := scanCiLogs(t, &CiLogsPlugin{Provider: ciProviderGithub, BaseURL: server.URL, Repo: "octo/app", Since: "2023-05-01", Until: "2023-05-31"})
+
+	assertCiLogItems(t, items, []Item{
+		{Content: "2023-05-02T10:00:00Z echo TOKEN=123", ID: "https://github.com/octo/app/actions/runs/1; Log: 0_build.txt", Metadata: map[string]string{ciJobKey: "CI"}},
+	})
+}
+
+func TestCiLogsGitlab(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch r.URL.Path {
+		case "/api/v4/projects/group/app/jobs":
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				response = []map[string]interface{}{
+					{"id": 3, "name": "deploy", "web_url": "https://gitlab.com/group/app/-/jobs/3", "created_at": "2023-06-02T10:00:00Z"},
+					{"id": 2, "name": "test", "web_url": "https://gitlab.com/group/app/-/jobs/2", "created_at": "2023-05-20T10:00:00Z"},
+					{"id": 4, "name": "lint", "web_url": "https://gitlab.com/group/app/-/jobs/4", "created_at": "2023-05-10T10:00:00Z"},
+				}
+			} else {
+				w.Header().Set("X-Next-Page", "3")
+				response = []map[string]interface{}{
+					{"id": 1, "name": "build", "web_url": "https://gitlab.com/group/app/-/jobs/1", "created_at": "2023-04-20T10:00:00Z"},
+				}
+			}
+		case "/api/v4/projects/group/app/jobs/4/trace":
+			// the trace was erased
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case "/api/v4/projects/group/app/jobs/2/trace":
+			_, _ = w.Write([]byte("\x1b[0KRunning tests with PASSWORD=123"))
+			return
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("error while encoding response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	items := scanCiLogs(t, &CiLogsPlugin{Provider: ciProviderGitlab, BaseURL: server.URL, Repo: "group/app", Since: "2023-05-01", Until: "2023-05-31"})
+
+	assertCiLogItems(t, items, []Item{
+		{Content: "Running tests with PASSWORD=123", ID: "https://gitlab.com/group/app/-/jobs/2", Metadata: map[string]string{ciJobKey: "test"}},
+	})
+}
+
+func TestCiLogsJenkins(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/job/folder/job/app/api/json":
+			inRange := time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC).UnixMilli()
+			outOfRange := time.Date(2023, 7, 10, 0, 0, 0, 0, time.UTC).UnixMilli()
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"allBuilds": [
+				{"number": 2, "url": "%[1]s/job/folder/job/app/2/", "timestamp": %[2]d, "fullDisplayName": "folder » app #2"},
+				{"number": 1, "url": "%[1]s/job/folder/job/app/1/", "timestamp": %[3]d, "fullDisplayName": "folder » app #1"}
+			]}`, server.URL, outOfRange, inRange)))
+		case "/job/folder/job/app/1/consoleText":
+			_, _ = w.Write([]byte("+ curl -H 'Authorization: token abc'"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	items := scanCiLogs(t, &CiLogsPlugin{Provider: ciProviderJenkins, BaseURL: server.URL, Repo: "folder/app", Username: "admin", Token: "token", Since: "2023-05-01", Until: "2023-05-31"})
+
+	assertCiLogItems(t, items, []Item{
+		{Content: "+ curl -H 'Authorization: token abc'", ID: server.URL + "/job/folder/job/app/1/consoleText", Metadata: map[string]string{ciJobKey: "folder » app #1"}},
+	})
+}
@@ -4,31 +4,66 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 
 	"github.com/checkmarx/2ms/lib"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 const (
-	argUrl                  = "url"
-	argSpaces               = "spaces"
-	argUsername             = "username"
-	argToken                = "token"
-	argHistory              = "history"
-	confluenceDefaultWindow = 25
-	confluenceMaxRequests   = 500
+	argUrl                 = "url"
+	argSpaces              = "spaces"
+	argUsername            = "username"
+	argToken               = "token"
+	argHistory             = "history"
+	argAttachments         = "attachments"
+	argComments            = "comments"
+	argBlogPosts           = "blogposts"
+	argTemplates           = "templates"
+	argArchived            = "archived"
+	argCql                 = "cql"
+	argAuthType            = "auth-type"
+	argOAuthTokenUrl       = "oauth-token-url"
+	argOAuthClientId       = "oauth-client-id"
+	argOAuthClientSecret   = "oauth-client-secret"
+	argOAuthScopes         = "oauth-scopes"
+	argLimit               = "limit"
+	confluenceDefaultLimit = 25
+	confluenceMaxRequests  = 500
+)
+
+const (
+	confluenceTypePage       = "page"
+	confluenceTypeBlogPost   = "blogpost"
+	confluenceTypeComment    = "comment"
+	confluenceTypeAttachment = "attachment"
+	confluenceTypeTemplate   = "template"
+	confluenceStatusArchived = "archived"
+	confluenceContentTypeKey = "contentType"
 )
 
 type ConfluencePlugin struct {
 	Plugin
-	URL      string
-	Token    string
-	Username string
-	Spaces   []string
-	History  bool
+	URL         string
+	Token       string
+	Username    string
+	Spaces      []string
+	History     bool
+	Attachments bool
+	Comments    bool
+	BlogPosts   bool
+	Templates   bool
+	Archived    bool
+	CQL         string
+	AuthType    string
+	// Number of results requested per page, the server may return less
+	ResultsLimit int
+
+	oauth *lib.OAuthClientCredentials
 }
 
 func (p *ConfluencePlugin) GetName() string {
@@ -39,8 +74,19 @@ func (p *ConfluencePlugin) GetCredentials() (string, string) {
 	return p.Username, p.Token
 }
 
-func (p *ConfluencePlugin) GetAuthorizationHeader() string {
-	return lib.CreateBasicAuthCredentials(p)
+func (p *ConfluencePlugin) GetAuthorizationHeader() (string, error) {
+	switch p.AuthType {
+	case lib.AuthTypeBearer:
+		return lib.CreateBearerAuthCredentials(p.Token), nil
+	case lib.AuthTypeOAuth2:
+		token, err := p.oauth.GetToken()
+		if err != nil {
+			return "", fmt.Errorf("error while getting confluence oauth token: %w", err)
+		}
+		return lib.CreateBearerAuthCredentials(token), nil
+	default:
+		return lib.CreateBasicAuthCredentials(p), nil
+	}
 }
 
 func (p *ConfluencePlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
@@ -54,8 +100,21 @@ func (p *ConfluencePlugin) DefineCommand(channels Channels) (*cobra.Command, err
 	flags.String(argUrl, "", "Confluence server URL (example: https://company.atlassian.net/wiki) [required]")
 	flags.StringArray(argSpaces, []string{}, "Confluence spaces: The names or IDs of the spaces to scan")
 	flags.String(argUsername, "", "Confluence user name or email for authentication")
-	flags.String(argToken, "", "The Confluence API token for authentication")
+	flags.String(argToken, "", "The Confluence API token (basic) or personal access token (bearer) for authentication")
+	flags.String(argAuthType, lib.AuthTypeBasic, fmt.Sprintf("Confluence authentication type (%s, %s, %s)", lib.AuthTypeBasic, lib.AuthTypeBearer, lib.AuthTypeOAuth2))
+	flags.String(argOAuthTokenUrl, "", "OAuth 2.0 token endpoint URL, used with the oauth2 authentication type")
+	flags.String(argOAuthClientId, "", "OAuth 2.0 client ID, used with the oauth2 authentication type")
+	flags.String(argOAuthClientSecret, "", "OAuth 2.0 client secret, used with the oauth2 authentication type")
+	flags.StringSlice(argOAuthScopes, []string{}, "OAuth 2.0 scopes to request, used with the oauth2 authentication type")
 	flags.Bool(argHistory, false, "Scan pages history")
+	flags.Int(argLimit, confluenceDefaultLimit, "Number of results requested per API call, the server may apply a lower maximum")
+	flags.Bool(argAttachments, false, "Scan pages attachments (text, PDF and Office documents)")
+	flags.Bool(argComments, false, "Scan pages comments (footer and inline)")
+	flags.Bool(argBlogPosts, true, "Scan blog posts")
+	flags.Bool(argTemplates, false, "Scan spaces page templates")
+	flags.Bool(argArchived, false, "Scan archived pages")
+	flags.String(argCql, "", "Scan the content matching a CQL query instead of whole spaces (example: 'label = \"runbook\" and space in (OPS, SRE)')")
+	confluenceCmd.MarkFlagsMutuallyExclusive(argCql, argSpaces)
 	err := confluenceCmd.MarkFlagRequired(argUrl)
 	if err != nil {
 		return nil, fmt.Errorf("error while marking '%s' flag as required: %w", argUrl, err)
@@ -87,9 +146,35 @@ func (p *ConfluencePlugin) initialize(cmd *cobra.Command) error {
 	username, _ := flags.GetString(argUsername)
 	token, _ := flags.GetString(argToken)
 	runHistory, _ := flags.GetBool(argHistory)
+	attachments, _ := flags.GetBool(argAttachments)
+	comments, _ := flags.GetBool(argComments)
+	blogPosts, _ := flags.GetBool(argBlogPosts)
+	templates, _ := flags.GetBool(argTemplates)
+	archived, _ := flags.GetBool(argArchived)
+	cql, _ := flags.GetString(argCql)
+	authType, _ := flags.GetString(argAuthType)
+	limit, _ := flags.GetInt(argLimit)
+	if limit <= 0 {
+		return fmt.Errorf("'%s' flag must be a positive number", argLimit)
+	}
 
-	if username == "" || token == "" {
-		log.Warn().Msg("confluence credentials were not provided. The scan will be made anonymously only for the public pages")
+	switch strings.ToLower(authType) {
+	case lib.AuthTypeBasic:
+		if username == "" || token == "" {
+			log.Warn().Msg("confluence credentials were not provided. The scan will be made anonymously only for the public pages")
+		}
+	case lib.AuthTypeBearer:
+		if token == "" {
+			return fmt.Errorf("'%s' flag is required for the %s authentication type", argToken, lib.AuthTypeBearer)
+		}
+	case lib.AuthTypeOAuth2:
+		oauth, err := p.initializeOAuth(flags)
+		if err != nil {
+			return err
+		}
+		p.oauth = oauth
+	default:
+		return fmt.Errorf("invalid authentication type: %s, available types are: %s, %s and %s", authType, lib.AuthTypeBasic, lib.AuthTypeBearer, lib.AuthTypeOAuth2)
 	}
 
 	p.Token = token
@@ -97,36 +182,155 @@ func (p *ConfluencePlugin) initialize(cmd *cobra.Command) error {
 	p.URL = url
 	p.Spaces = spaces
 	p.History = runHistory
+	p.Attachments = attachments
+	p.Comments = comments
+	p.BlogPosts = blogPosts
+	p.Templates = templates
+	p.Archived = archived
+	p.CQL = cql
+	p.AuthType = strings.ToLower(authType)
+	p.ResultsLimit = limit
 	p.Limit = make(chan struct{}, confluenceMaxRequests)
 	return nil
 }
 
+func (p *ConfluencePlugin) initializeOAuth(flags *pflag.FlagSet) (*lib.OAuthClientCredentials, error) {
+	tokenUrl, _ := flags.GetString(argOAuthTokenUrl)
+	clientId, _ := flags.GetString(argOAuthClientId)
+	clientSecret, _ := flags.GetString(argOAuthClientSecret)
+	scopes, _ := flags.GetStringSlice(argOAuthScopes)
+
+	if tokenUrl == "" || clientId == "" || clientSecret == "" {
+		return nil, fmt.Errorf("'%s', '%s' and '%s' flags are required for the %s authentication type", argOAuthTokenUrl, argOAuthClientId, argOAuthClientSecret, lib.AuthTypeOAuth2)
+	}
+
+	oauth := lib.NewOAuthClientCredentials(tokenUrl, clientId, clientSecret, scopes)
+	if _, err := oauth.GetToken(); err != nil {
+		return nil, fmt.Errorf("error while getting confluence oauth token: %w", err)
+	}
+	return oauth, nil
+}
+
 func (p *ConfluencePlugin) getItems(items chan Item, errs chan error, wg *sync.WaitGroup) {
+	if p.CQL != "" {
+		p.getCqlItems(items, errs, wg)
+		return
+	}
 	p.getSpacesItems(items, errs, wg)
 }
 
+func (p *ConfluencePlugin) getCqlItems(items chan Item, errs chan error, wg *sync.WaitGroup) {
+	searchUrl := fmt.Sprintf("%s/rest/api/content/search?cql=%s&expand=space&limit=%d", p.URL, url.QueryEscape(p.CQL), p.ResultsLimit)
+	total := 0
+	// Attachments are downloaded once the search is over, so a slow download does not hold the paging
+	attachments := []ConfluenceChildContent{}
+
+	err := getConfluenceResults(p, searchUrl, func(results []ConfluenceSearchContent) error {
+		for _, content := range results {
+			total++
+			if content.Type == confluenceTypeAttachment {
+				attachments = append(attachments, content.ConfluenceChildContent)
+				continue
+			}
+
+			wg.Add(1)
+			p.Limit <- struct{}{}
+			go func(content ConfluenceSearchContent) {
+				p.getPageItems(items, errs, wg, content.getPage(), content.Space)
+				<-p.Limit
+			}(content)
+		}
+		return nil
+	})
+	if err != nil {
+		errs <- fmt.Errorf("unexpected error searching content with cql: %w", err)
+		return
+	}
+
+	log.Info().Msgf(" Total of %d contents matched the cql query", total)
+
+	for _, attachment := range attachments {
+		wg.Add(1)
+		p.Limit <- struct{}{}
+		go func(attachment ConfluenceChildContent) {
+			defer wg.Done()
+			defer func() { <-p.Limit }()
+
+			item, err := p.getAttachmentItem(attachment)
+			if err != nil {
+				log.Warn().Msgf("Skipping attachment %s: %s", attachment.Title, err)
+				return
+			}
+			items <- *item
+		}(attachment)
+	}
+}
+
+// getConfluenceResults requests the given url and then follows the next links returned by the server,
+// calling handle with the results of each page
+func getConfluenceResults[T any](p *ConfluencePlugin, url string, handle func(results []T) error) error {
+	for url != "" {
+		body, _, err := lib.HttpRequest(http.MethodGet, url, p)
+		if err != nil {
+			return fmt.Errorf("unexpected error creating an http request %w", err)
+		}
+
+		response := ConfluenceResults[T]{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+
+		if err := handle(response.Results); err != nil {
+			return err
+		}
+
+		url = p.getNextUrl(response.Links)
+	}
+	return nil
+}
+
+// getNextUrl returns the absolute url of the next results page, or an empty string for the last page
+func (p *ConfluencePlugin) getNextUrl(links map[string]string) string {
+	next, ok := links["next"]
+	if !ok || next == "" {
+		return ""
+	}
+	if base, ok := links["base"]; ok && base != "" {
+		return base + next
+	}
+	return p.URL + next
+}
+
 func (p *ConfluencePlugin) getSpacesItems(items chan Item, errs chan error, wg *sync.WaitGroup) {
 	spaces, err := p.getSpaces()
 	if err != nil {
 		errs <- err
+		return
 	}
 
 	for _, space := range spaces {
-		go p.getSpaceItems(items, errs, wg, space)
 		wg.Add(1)
+		go p.getSpaceItems(items, errs, wg, space)
 	}
 }
 
 func (p *ConfluencePlugin) getSpaceItems(items chan Item, errs chan error, wg *sync.WaitGroup, space ConfluenceSpaceResult) {
 	defer wg.Done()
 
+	if p.Templates {
+		if err := p.getTemplatesItems(items, space); err != nil {
+			errs <- err
+			return
+		}
+	}
+
 	pages, err := p.getPages(space)
 	if err != nil {
 		errs <- err
 		return
 	}
 
-	for _, page := range pages.Pages {
+	for _, page := range pages {
 		wg.Add(1)
 		p.Limit <- struct{}{}
 		go func(page ConfluencePage) {
@@ -137,36 +341,26 @@ func (p *ConfluencePlugin) getSpaceItems(items chan Item, errs chan error, wg *s
 }
 
 func (p *ConfluencePlugin) getSpaces() ([]ConfluenceSpaceResult, error) {
-	totalSpaces, err := p.getSpacesRequest(0)
+	totalSpaces := []ConfluenceSpaceResult{}
+	spacesUrl := fmt.Sprintf("%s/rest/api/space?limit=%d", p.URL, p.ResultsLimit)
+	err := getConfluenceResults(p, spacesUrl, func(results []ConfluenceSpaceResult) error {
+		totalSpaces = append(totalSpaces, results...)
+		return nil
+	})
 	if err != nil {
-		return nil, err
-	}
-
-	actualSize := totalSpaces.Size
-
-	for actualSize == confluenceDefaultWindow {
-		moreSpaces, err := p.getSpacesRequest(totalSpaces.Size)
-		if err != nil {
-			return nil, err
-		}
-
-		totalSpaces.Results = append(totalSpaces.Results, moreSpaces.Results...)
-		totalSpaces.Size += moreSpaces.Size
-		actualSize = moreSpaces.Size
+		return nil, fmt.Errorf("unexpected error getting spaces: %w", err)
 	}
 
 	if len(p.Spaces) == 0 {
-		log.Info().Msgf(" Total of all %d Spaces detected", len(totalSpaces.Results))
-		return totalSpaces.Results, nil
+		log.Info().Msgf(" Total of all %d Spaces detected", len(totalSpaces))
+		return totalSpaces, nil
 	}
 
 	filteredSpaces := make([]ConfluenceSpaceResult, 0)
-	if len(p.Spaces) > 0 {
-		for _, space := range totalSpaces.Results {
-			for _, spaceToScan := range p.Spaces {
-				if space.Key == spaceToScan || space.Name == spaceToScan || fmt.Sprintf("%d", space.ID) == spaceToScan {
-					filteredSpaces = append(filteredSpaces, space)
-				}
+	for _, space := range totalSpaces {
+		for _, spaceToScan := range p.Spaces {
+			if space.Key == spaceToScan || space.Name == spaceToScan || fmt.Sprintf("%d", space.ID) == spaceToScan {
+				filteredSpaces = append(filteredSpaces, space)
 			}
 		}
 	}
@@ -175,62 +369,34 @@ func (p *ConfluencePlugin) getSpaces() ([]ConfluenceSpaceResult, error) {
 	return filteredSpaces, nil
 }
 
-func (p *ConfluencePlugin) getSpacesRequest(start int) (*ConfluenceSpaceResponse, error) {
-	url := fmt.Sprintf("%s/rest/api/space?start=%d", p.URL, start)
-	body, _, err := lib.HttpRequest(http.MethodGet, url, p)
-	if err != nil {
-		return nil, fmt.Errorf("unexpected error creating an http request %w", err)
-	}
-
-	response := &ConfluenceSpaceResponse{}
-	jsonErr := json.Unmarshal(body, response)
-	if jsonErr != nil {
-		return nil, fmt.Errorf("could not unmarshal response %w", err)
-	}
-
-	return response, nil
-}
-
-func (p *ConfluencePlugin) getPages(space ConfluenceSpaceResult) (*ConfluencePageResult, error) {
-	totalPages, err := p.getPagesRequest(space, 0)
-
-	if err != nil {
-		return nil, fmt.Errorf("unexpected error creating an http request %w", err)
-	}
-
-	actualSize := len(totalPages.Pages)
-
-	for actualSize == confluenceDefaultWindow {
-		morePages, err := p.getPagesRequest(space, len(totalPages.Pages))
+func (p *ConfluencePlugin) getPages(space ConfluenceSpaceResult) ([]ConfluencePage, error) {
+	totalPages := []ConfluencePage{}
 
+	for _, contentUrl := range p.getContentUrls(space) {
+		err := getConfluenceResults(p, contentUrl, func(results []ConfluencePage) error {
+			totalPages = append(totalPages, results...)
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("unexpected error creating an http request %w", err)
+			return nil, fmt.Errorf("unexpected error getting pages of space %s: %w", space.Key, err)
 		}
-
-		totalPages.Pages = append(totalPages.Pages, morePages.Pages...)
-		actualSize = len(morePages.Pages)
 	}
 
-	log.Info().Msgf(" Space - %s have %d pages", space.Name, len(totalPages.Pages))
+	log.Info().Msgf(" Space - %s have %d pages", space.Name, len(totalPages))
 
 	return totalPages, nil
 }
 
-func (p *ConfluencePlugin) getPagesRequest(space ConfluenceSpaceResult, start int) (*ConfluencePageResult, error) {
-	url := fmt.Sprintf("%s/rest/api/space/%s/content?start=%d", p.URL, space.Key, start)
-	body, _, err := lib.HttpRequest(http.MethodGet, url, p)
-
-	if err != nil {
-		return nil, fmt.Errorf("unexpected error creating an http request %w", err)
+// getContentUrls returns the urls listing the space content of the selected types
+func (p *ConfluencePlugin) getContentUrls(space ConfluenceSpaceResult) []string {
+	urls := []string{fmt.Sprintf("%s/rest/api/space/%s/content/%s?limit=%d", p.URL, space.Key, confluenceTypePage, p.ResultsLimit)}
+	if p.BlogPosts {
+		urls = append(urls, fmt.Sprintf("%s/rest/api/space/%s/content/%s?limit=%d", p.URL, space.Key, confluenceTypeBlogPost, p.ResultsLimit))
 	}
-
-	response := ConfluencePageResponse{}
-	jsonErr := json.Unmarshal(body, &response)
-	if jsonErr != nil {
-		return nil, fmt.Errorf("could not unmarshal response %w", err)
+	if p.Archived {
+		urls = append(urls, fmt.Sprintf("%s/rest/api/content?spaceKey=%s&type=%s&status=%s&limit=%d", p.URL, space.Key, confluenceTypePage, confluenceStatusArchived, p.ResultsLimit))
 	}
-
-	return &response.Results, nil
+	return urls
 }
 
 func (p *ConfluencePlugin) getPageItems(items chan Item, errs chan error, wg *sync.WaitGroup, page ConfluencePage, space ConfluenceSpaceResult) {
@@ -243,6 +409,20 @@ func (p *ConfluencePlugin) getPageItems(items chan Item, errs chan error, wg *sy
 	}
 	items <- *actualPage
 
+	if p.Comments {
+		if err := p.getCommentsItems(items, page, space); err != nil {
+			errs <- err
+			return
+		}
+	}
+
+	if p.Attachments {
+		if err := p.getAttachmentsItems(items, page); err != nil {
+			errs <- err
+			return
+		}
+	}
+
 	// If older versions exist & run history is true
 	for previousVersion > 0 && p.History {
 		actualPage, previousVersion, err = p.getItem(page, space, previousVersion)
@@ -261,11 +441,14 @@ func (p *ConfluencePlugin) getItem(page ConfluencePage, space ConfluenceSpaceRes
 	// If no version given get the latest, else get the specified version
 	if version == 0 {
 		url = fmt.Sprintf("%s/rest/api/content/%s?expand=body.storage.value,version,history.previousVersion", p.URL, page.ID)
-		originalUrl = fmt.Sprintf("%s/spaces/%s/pages/%s", p.URL, space.Key, page.ID)
+		if page.Status == confluenceStatusArchived {
+			url += "&status=" + confluenceStatusArchived
+		}
+		originalUrl = p.getPageUrl(page, space)
 
 	} else {
 		url = fmt.Sprintf("%s/rest/api/content/%s?status=historical&version=%d&expand=body.storage.value,version,history.previousVersion", p.URL, page.ID, version)
-		originalUrl = fmt.Sprintf("%s/pages/viewpage.action?pageid=%spageVersion=%d", p.URL, page.ID, version)
+		originalUrl = p.getPageVersionUrl(page, version)
 	}
 
 	request, _, err := lib.HttpRequest(http.MethodGet, url, p)
@@ -278,11 +461,119 @@ func (p *ConfluencePlugin) getItem(page ConfluencePage, space ConfluenceSpaceRes
 		return nil, 0, jsonErr
 	}
 
-	content := &Item{
-		Content: pageContent.Body.Storage.Value,
-		ID:      originalUrl,
+	content := newConfluenceStorageItem(pageContent.Body.Storage.Value, originalUrl, page.Type)
+	return &content, pageContent.History.PreviousVersion.Number, nil
+}
+
+// newConfluenceStorageItem creates an item with the text of a storage format content, mapped to its original lines
+func newConfluenceStorageItem(storage string, id string, contentType string) Item {
+	item := Item{
+		Content:  storage,
+		ID:       id,
+		Metadata: map[string]string{confluenceContentTypeKey: contentType},
 	}
-	return content, pageContent.History.PreviousVersion.Number, nil
+
+	text, lines, err := convertHtmlToText(storage)
+	if err != nil {
+		log.Debug().Msgf("Scanning raw storage format of %s, conversion to text failed: %s", id, err)
+		return item
+	}
+	item.Content = text
+	item.LineMapping = lines
+	return item
+}
+
+func (p *ConfluencePlugin) getPageUrl(page ConfluencePage, space ConfluenceSpaceResult) string {
+	if page.Type == confluenceTypePage || page.Type == "" {
+		return fmt.Sprintf("%s/spaces/%s/pages/%s", p.URL, space.Key, page.ID)
+	}
+	if webui, ok := page.Links["webui"]; ok {
+		return p.URL + webui
+	}
+	return fmt.Sprintf("%s/pages/viewpage.action?pageId=%s", p.URL, page.ID)
+}
+
+func (p *ConfluencePlugin) getPageVersionUrl(page ConfluencePage, version int) string {
+	return fmt.Sprintf("%s/pages/viewpage.action?pageId=%s&pageVersion=%d", p.URL, page.ID, version)
+}
+
+func (p *ConfluencePlugin) getTemplatesItems(items chan Item, space ConfluenceSpaceResult) error {
+	templatesUrl := fmt.Sprintf("%s/rest/api/template/page?spaceKey=%s&expand=body&limit=%d", p.URL, space.Key, p.ResultsLimit)
+	err := getConfluenceResults(p, templatesUrl, func(results []ConfluenceTemplate) error {
+		for _, template := range results {
+			id := fmt.Sprintf("%s/pages/templates2/viewpagetemplate.action?entityId=%s&key=%s", p.URL, template.TemplateID, space.Key)
+			items <- newConfluenceStorageItem(template.Body.Storage.Value, id, confluenceTypeTemplate)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unexpected error getting templates of space %s: %w", space.Key, err)
+	}
+	return nil
+}
+
+func (p *ConfluencePlugin) getCommentsItems(items chan Item, page ConfluencePage, space ConfluenceSpaceResult) error {
+	commentsUrl := fmt.Sprintf("%s/rest/api/content/%s/child/comment?expand=body.storage&depth=all&location=footer&location=inline&location=resolved&limit=%d", p.URL, page.ID, p.ResultsLimit)
+	err := getConfluenceResults(p, commentsUrl, func(results []ConfluenceChildContent) error {
+		for _, comment := range results {
+			id := fmt.Sprintf("%s?focusedCommentId=%s#comment-%s", p.getPageUrl(page, space), comment.ID, comment.ID)
+			items <- newConfluenceStorageItem(comment.Body.Storage.Value, id, confluenceTypeComment)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unexpected error getting comments of page %s: %w", page.ID, err)
+	}
+	return nil
+}
+
+func (p *ConfluencePlugin) getAttachmentsItems(items chan Item, page ConfluencePage) error {
+	attachmentsUrl := fmt.Sprintf("%s/rest/api/content/%s/child/attachment?limit=%d", p.URL, page.ID, p.ResultsLimit)
+	err := getConfluenceResults(p, attachmentsUrl, func(results []ConfluenceChildContent) error {
+		for _, attachment := range results {
+			item, err := p.getAttachmentItem(attachment)
+			if err != nil {
+				log.Warn().Msgf("Skipping attachment %s of page %s: %s", attachment.Title, page.ID, err)
+				continue
+			}
+			items <- *item
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unexpected error getting attachments of page %s: %w", page.ID, err)
+	}
+	return nil
+}
+
+func (p *ConfluencePlugin) getAttachmentItem(attachment ConfluenceChildContent) (*Item, error) {
+	if attachment.Extensions.FileSize > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment size %d exceeds the limit of %d bytes", attachment.Extensions.FileSize, maxAttachmentSize)
+	}
+
+	url := p.URL + attachment.Links["download"]
+	body, _, err := lib.HttpRequest(http.MethodGet, url, p)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error downloading attachment %w", err)
+	}
+
+	content, err := getAttachmentContent(attachment.Title, attachment.Extensions.MediaType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Item{
+		Content:  content,
+		ID:       url,
+		Metadata: map[string]string{confluenceContentTypeKey: confluenceTypeAttachment},
+	}, nil
+}
+
+// ConfluenceResults is a page of results returned by the Confluence REST API
+type ConfluenceResults[T any] struct {
+	Results []T               `json:"results"`
+	Size    int               `json:"size"`
+	Links   map[string]string `json:"_links"`
 }
 
 type ConfluenceSpaceResult struct {
@@ -292,11 +583,6 @@ type ConfluenceSpaceResult struct {
 	Links map[string]string `json:"_links"`
 }
 
-type ConfluenceSpaceResponse struct {
-	Results []ConfluenceSpaceResult `json:"results"`
-	Size    int                     `json:"size"`
-}
-
 type ConfluencePageContent struct {
 	Body struct {
 		Storage struct {
@@ -314,15 +600,51 @@ type ConfluencePageContent struct {
 }
 
 type ConfluencePage struct {
+	ID     string            `json:"id"`
+	Type   string            `json:"type"`
+	Status string            `json:"status"`
+	Title  string            `json:"title"`
+	Links  map[string]string `json:"_links"`
+}
+
+type ConfluenceTemplate struct {
+	TemplateID string `json:"templateId"`
+	Name       string `json:"name"`
+	Body       struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+}
+
+type ConfluenceChildContent struct {
 	ID    string `json:"id"`
 	Type  string `json:"type"`
 	Title string `json:"title"`
+	Body  struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Extensions struct {
+		MediaType string `json:"mediaType"`
+		FileSize  int    `json:"fileSize"`
+	} `json:"extensions"`
+	Links map[string]string `json:"_links"`
 }
 
-type ConfluencePageResult struct {
-	Pages []ConfluencePage `json:"results"`
+type ConfluenceSearchContent struct {
+	ConfluenceChildContent
+	Status string                `json:"status"`
+	Space  ConfluenceSpaceResult `json:"space"`
 }
 
-type ConfluencePageResponse struct {
-	Results ConfluencePageResult `json:"page"`
+func (c ConfluenceSearchContent) getPage() ConfluencePage {
+	return ConfluencePage{
+		ID:     c.ID,
+		Type:   c.Type,
+		Status: c.Status,
+		Title:  c.Title,
+		Links:  c.Links,
+	}
 }
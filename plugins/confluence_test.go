@@ -0,0 +1,323 @@
+package plugins
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/checkmarx/2ms/lib"
+)
+
+func TestConfluenceGetSpacesFollowsNextLinks(t *testing.T) {
+	pages := map[string]string{
+		"0": `{"results": [{"id": 1, "key": "ONE"}, {"id": 2, "key": "TWO"}], "size": 2, "_links": {"next": "/rest/api/space?limit=50&start=2"}}`,
+		"2": `{"results": [{"id": 3, "key": "THREE"}], "size": 1, "_links": {}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := r.URL.Query().Get("start")
+		if start == "" {
+			start = "0"
+		}
+		if r.URL.Path != "/rest/api/space" || r.URL.Query().Get("limit") != "50" {
+			t.Errorf("unexpected request: %s", r.URL.String())
+		}
+		fmt.Fprint(w, pages[start])
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		spaces       []string
+		expectedKeys []string
+	}{
+		{
+			name:         "all spaces",
+			expectedKeys: []string{"ONE", "TWO", "THREE"},
+		},
+		{
+			name:         "filtered spaces",
+			spaces:       []string{"THREE", "1"},
+			expectedKeys: []string{"ONE", "THREE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ConfluencePlugin{URL: server.URL, Spaces: tt.spaces, ResultsLimit: 50}
+			spaces, err := p.getSpaces()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(spaces) != len(tt.expectedKeys) {
+				t.Fatalf("expected %d spaces, but got %d", len(tt.expectedKeys), len(spaces))
+			}
+			for i, space := range spaces {
+				if space.Key != tt.expectedKeys[i] {
+					t.Errorf("expected space %s, but got %s", tt.expectedKeys[i], space.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestConfluenceGetNextUrl(t *testing.T) {
+	p := &ConfluencePlugin{URL: "https://company.atlassian.net/wiki"}
+
+	tests := []struct {
+		name     string
+		links    map[string]string
+		expected string
+	}{
+		{
+			name:     "last page",
+			links:    map[string]string{"base": "https://company.atlassian.net/wiki"},
+			expected: "",
+		},
+		{
+			name:     "next link relative to base",
+			links:    map[string]string{"base": "https://other.host/wiki", "next": "/rest/api/space?start=25"},
+			expected: "https://other.host/wiki/rest/api/space?start=25",
+		},
+		{
+			name:     "next link without base",
+			links:    map[string]string{"next": "/rest/api/space?start=25"},
+			expected: "https://company.atlassian.net/wiki/rest/api/space?start=25",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if next := p.getNextUrl(tt.links); next != tt.expected {
+				t.Errorf("expected %q, but got %q", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestConfluenceGetPageVersionUrl(t *testing.T) {
+	p := &ConfluencePlugin{URL: "https://company.atlassian.net/wiki"}
+
+	url := p.getPageVersionUrl(ConfluencePage{ID: "1234"}, 3)
+
+	expected := "https://company.atlassian.net/wiki/pages/viewpage.action?pageId=1234&pageVersion=3"
+	if url != expected {
+		t.Errorf("expected %q, but got %q", expected, url)
+	}
+}
+
+func TestConfluenceSpaceContentTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch r.URL.Path {
+		case "/rest/api/space":
+			fmt.Fprint(w, `{"results": [{"id": 1, "key": "ENG"}]}`)
+		case "/rest/api/space/ENG/content/page":
+			fmt.Fprint(w, `{"results": [{"id": "10", "type": "page", "status": "current"}]}`)
+		case "/rest/api/space/ENG/content/blogpost":
+			fmt.Fprint(w, `{"results": [{"id": "20", "type": "blogpost", "status": "current", "_links": {"webui": "/spaces/ENG/blog/2023/06/01/20/Release"}}]}`)
+		case "/rest/api/content":
+			if query.Get("spaceKey") != "ENG" || query.Get("type") != confluenceTypePage || query.Get("status") != confluenceStatusArchived {
+				t.Errorf("unexpected archived content request: %s", r.URL.String())
+			}
+			fmt.Fprint(w, `{"results": [{"id": "30", "type": "page", "status": "archived"}]}`)
+		case "/rest/api/template/page":
+			if query.Get("spaceKey") != "ENG" {
+				t.Errorf("unexpected templates request: %s", r.URL.String())
+			}
+			fmt.Fprint(w, `{"results": [{"templateId": "40", "body": {"storage": {"value": "<p>template password=40</p>"}}}]}`)
+		case "/rest/api/content/10", "/rest/api/content/20", "/rest/api/content/30":
+			id := strings.TrimPrefix(r.URL.Path, "/rest/api/content/")
+			if id == "30" && query.Get("status") != confluenceStatusArchived {
+				t.Errorf("archived page requested without its status: %s", r.URL.String())
+			}
+			fmt.Fprintf(w, `{"body": {"storage": {"value": "<p>password=%s</p>"}}}`, id)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	page := Item{Content: "password=10", ID: server.URL + "/spaces/ENG/pages/10", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypePage}}
+	blogPost := Item{Content: "password=20", ID: server.URL + "/spaces/ENG/blog/2023/06/01/20/Release", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypeBlogPost}}
+	archived := Item{Content: "password=30", ID: server.URL + "/spaces/ENG/pages/30", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypePage}}
+	template := Item{Content: "template password=40", ID: server.URL + "/pages/templates2/viewpagetemplate.action?entityId=40&key=ENG", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypeTemplate}}
+
+	tests := []struct {
+		name          string
+		plugin        ConfluencePlugin
+		expectedItems []Item
+	}{
+		{
+			name:          "pages only",
+			expectedItems: []Item{page},
+		},
+		{
+			name:          "blog posts",
+			plugin:        ConfluencePlugin{BlogPosts: true},
+			expectedItems: []Item{blogPost, page},
+		},
+		{
+			name:          "templates and archived pages",
+			plugin:        ConfluencePlugin{BlogPosts: true, Templates: true, Archived: true},
+			expectedItems: []Item{template, blogPost, page, archived},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := tt.plugin
+			p.URL = server.URL
+			p.ResultsLimit = confluenceDefaultLimit
+			p.Limit = make(chan struct{}, confluenceMaxRequests)
+
+			items := scanConfluence(t, &p)
+
+			if len(items) != len(tt.expectedItems) {
+				t.Fatalf("expected %d items, but got %d: %v", len(tt.expectedItems), len(items), items)
+			}
+			for i, item := range items {
+				expected := tt.expectedItems[i]
+				if item.ID != expected.ID || item.Content != expected.Content || !reflect.DeepEqual(item.Metadata, expected.Metadata) {
+					t.Errorf("expected item %v, but got %v", expected, item)
+				}
+			}
+		})
+	}
+}
+
+func scanConfluence(t *testing.T, p *ConfluencePlugin) []Item {
+	items := make(chan Item)
+	errs := make(chan error, 1)
+	wg := &sync.WaitGroup{}
+
+	p.getItems(items, errs, wg)
+	go func() {
+		wg.Wait()
+		close(items)
+	}()
+
+	result := []Item{}
+	for item := range items {
+		result = append(result, item)
+	}
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	// pages are fetched concurrently
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func TestConfluenceCqlFollowsNextLinks(t *testing.T) {
+	cql := `label = "runbook"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/api/content/search":
+			if r.URL.Query().Get("cql") != cql {
+				t.Errorf("unexpected cql: %s", r.URL.Query().Get("cql"))
+			}
+			if r.URL.Query().Get("cursor") == "" {
+				fmt.Fprint(w, `{"results": [
+					{"id": "10", "type": "page", "status": "current", "space": {"key": "OPS"}},
+					{"id": "att20", "type": "attachment", "title": "notes.txt", "extensions": {"mediaType": "text/plain", "fileSize": 14}, "_links": {"download": "/download/attachments/10/notes.txt"}}
+				], "_links": {"next": "/rest/api/content/search?cql=label+%3D+%22runbook%22&cursor=2"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"results": [{"id": "30", "type": "blogpost", "status": "current", "space": {"key": "SRE"}, "_links": {"webui": "/spaces/SRE/blog/30"}}], "_links": {}}`)
+		case "/rest/api/content/10", "/rest/api/content/30":
+			fmt.Fprintf(w, `{"body": {"storage": {"value": "<p>password=%s</p>"}}}`, strings.TrimPrefix(r.URL.Path, "/rest/api/content/"))
+		case "/download/attachments/10/notes.txt":
+			fmt.Fprint(w, "password=att20")
+		default:
+			t.Errorf("unexpected request: %s", r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := &ConfluencePlugin{URL: server.URL, CQL: cql, ResultsLimit: confluenceDefaultLimit}
+	p.Limit = make(chan struct{}, confluenceMaxRequests)
+
+	items := scanConfluence(t, p)
+
+	expectedItems := []Item{
+		{Content: "password=att20", ID: server.URL + "/download/attachments/10/notes.txt", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypeAttachment}},
+		{Content: "password=10", ID: server.URL + "/spaces/OPS/pages/10", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypePage}},
+		{Content: "password=30", ID: server.URL + "/spaces/SRE/blog/30", Metadata: map[string]string{confluenceContentTypeKey: confluenceTypeBlogPost}},
+	}
+	if len(items) != len(expectedItems) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expectedItems), len(items), items)
+	}
+	for i, item := range items {
+		expected := expectedItems[i]
+		if item.ID != expected.ID || item.Content != expected.Content || !reflect.DeepEqual(item.Metadata, expected.Metadata) {
+			t.Errorf("expected item %v, but got %v", expected, item)
+		}
+	}
+}
+
+func TestConfluenceGetAuthorizationHeader(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rejected" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "oauth-token", "expires_in": 3600}`)
+	}))
+	defer tokenServer.Close()
+
+	tests := []struct {
+		name           string
+		plugin         ConfluencePlugin
+		expectedHeader string
+		expectedError  bool
+	}{
+		{
+			name:           "basic",
+			plugin:         ConfluencePlugin{AuthType: lib.AuthTypeBasic, Username: "user", Token: "token"},
+			expectedHeader: "Basic dXNlcjp0b2tlbg==",
+		},
+		{
+			name:           "bearer",
+			plugin:         ConfluencePlugin{AuthType: lib.AuthTypeBearer, Token: "token"},
+			expectedHeader: "Bearer token",
+		},
+		{
+			name:           "oauth2",
+			plugin:         ConfluencePlugin{AuthType: lib.AuthTypeOAuth2, oauth: lib.NewOAuthClientCredentials(tokenServer.URL, "id", "secret", nil)},
+			expectedHeader: "Bearer oauth-token",
+		},
+		{
+			name:          "oauth2 token failure",
+			plugin:        ConfluencePlugin{AuthType: lib.AuthTypeOAuth2, oauth: lib.NewOAuthClientCredentials(tokenServer.URL+"/rejected", "id", "secret", nil)},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, err := tt.plugin.GetAuthorizationHeader()
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected an error, but got header %q", header)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if header != tt.expectedHeader {
+				t.Errorf("expected header %q, but got %q", tt.expectedHeader, header)
+			}
+		})
+	}
+}
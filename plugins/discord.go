@@ -1,7 +1,13 @@
 package plugins
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,28 +18,74 @@ import (
 )
 
 const (
-	tokenFlag         = "token"
-	serversFlag       = "server"
-	channelsFlag      = "channel"
-	fromDateFlag      = "duration"
-	messagesCountFlag = "messages-count"
+	tokenFlag          = "token"
+	serversFlag        = "server"
+	channelsFlag       = "channel"
+	fromDateFlag       = "duration"
+	messagesCountFlag  = "messages-count"
+	discordRestFlag    = "rest"
+	discordBaseUrlFlag = "base-url"
 )
 
 const defaultDateFrom = time.Hour * 24 * 14
 
+const (
+	discordDefaultBaseUrl   = "https://discord.com/"
+	discordGuildsPageSize   = 200
+	discordThreadsPageSize  = 100
+	discordMessagesPageSize = 100
+	discordReadyTimeout     = time.Second * 10
+)
+
+// Types of the channels holding messages, forum channels hold their posts as threads
+var discordScannedChannelTypes = map[discordgo.ChannelType]bool{
+	discordgo.ChannelTypeGuildText:  true,
+	discordgo.ChannelTypeGuildNews:  true,
+	discordgo.ChannelTypeGuildForum: true,
+}
+
+// https://discord.com/developers/docs/topics/opcodes-and-status-codes#json
+const (
+	discordMissingAccessCode     = 50001
+	discordMissingPermissionCode = 50013
+)
+
 type DiscordPlugin struct {
 	Token            string
 	Guilds           []string
 	Channels         []string
 	Count            int
 	BackwardDuration time.Duration
-	Session          *discordgo.Session
-
+	RestOnly         bool
+	BaseUrl          string
+	Session          IDiscordSession
+	State            *discordgo.State
+	HttpClient       *http.Client
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	errOnce   sync.Once
 	errChan   chan error
 	itemChan  chan Item
 	waitGroup *sync.WaitGroup
 }
 
+// IDiscordSession is the part of the discordgo session used by the plugin
+type IDiscordSession interface {
+	Open() error
+	Close() error
+	AddHandlerOnce(handler interface{}) func()
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	UserGuilds(limit int, beforeID, afterID string, options ...discordgo.RequestOption) ([]*discordgo.UserGuild, error)
+	UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error)
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error)
+	GuildThreadsActive(guildID string, options ...discordgo.RequestOption) (*discordgo.ThreadsList, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ThreadsArchived(channelID string, before *time.Time, limit int, options ...discordgo.RequestOption) (*discordgo.ThreadsList, error)
+}
+
 func (p *DiscordPlugin) GetName() string {
 	return "discord"
 }
@@ -59,6 +111,8 @@ func (p *DiscordPlugin) DefineCommand(channels Channels) (*cobra.Command, error)
 	flags.StringArray(channelsFlag, []string{}, "Discord channels IDs to scan. If not provided, all channels will be scanned")
 	flags.Duration(fromDateFlag, defaultDateFrom, "The time interval to scan from the current time. For example, 24h for 24 hours or 7d for 7 days.")
 	flags.Int(messagesCountFlag, 0, "The number of messages to scan. If not provided, all messages will be scanned until the fromDate flag value.")
+	flags.Bool(discordRestFlag, false, "Use the REST API only to list the servers and channels, without opening a Gateway websocket")
+	flags.String(discordBaseUrlFlag, discordDefaultBaseUrl, "Discord API base URL, for example a proxy")
 
 	discordCmd.Run = func(cmd *cobra.Command, args []string) {
 		err := p.initialize(cmd)
@@ -66,8 +120,13 @@ func (p *DiscordPlugin) DefineCommand(channels Channels) (*cobra.Command, error)
 			channels.Errors <- fmt.Errorf("discord plugin initialization failed: %w", err)
 			return
 		}
+		err = p.newSession()
+		if err != nil {
+			channels.Errors <- fmt.Errorf("discord plugin initialization failed: %w", err)
+			return
+		}
 
-		p.getItems(channels.Items, channels.Errors, channels.WaitGroup)
+		p.getItems(context.Background(), channels.Items, channels.Errors)
 	}
 
 	return discordCmd, nil
@@ -101,66 +160,214 @@ func (p *DiscordPlugin) initialize(cmd *cobra.Command) error {
 	p.Channels = channels
 	p.Count = count
 	p.BackwardDuration = fromDate
+	p.RestOnly, _ = flags.GetBool(discordRestFlag)
+	p.BaseUrl, _ = flags.GetString(discordBaseUrlFlag)
+
+	return nil
+}
+
+func (p *DiscordPlugin) newSession() error {
+	session, err := discordgo.New(p.Token)
+	if err != nil {
+		return err
+	}
+	session.StateEnabled = true
+	if err := p.setBaseUrl(session.Client); err != nil {
+		return err
+	}
 
+	p.Session = session
+	p.State = session.State
+	p.HttpClient = session.Client
 	return nil
 }
 
-func (p *DiscordPlugin) getItems(itemsChan chan Item, errChan chan error, wg *sync.WaitGroup) {
-	defer wg.Done()
+// getItems scans the selected guilds and returns once all their channels are scanned, or the context is canceled
+func (p *DiscordPlugin) getItems(ctx context.Context, itemsChan chan Item, errChan chan error) {
+	p.ctx, p.cancel = context.WithCancel(ctx)
+	defer p.cancel()
 
 	p.errChan = errChan
 	p.itemChan = itemsChan
-	p.waitGroup = wg
+	p.waitGroup = &sync.WaitGroup{}
+
+	defer func() {
+		if err := p.Session.Close(); err != nil {
+			log.Warn().Msgf("Failed to close discord session: %s", err)
+		}
+	}()
 
-	err := p.getDiscordReady()
+	var err error
+	if p.RestOnly {
+		err = p.getDiscordRestReady()
+	} else {
+		err = p.getDiscordReady()
+	}
 	if err != nil {
-		errChan <- err
+		p.reportError(err)
 		return
 	}
 
 	guilds := p.getGuildsByNameOrIDs()
 	log.Info().Msgf("Found %d guilds", len(guilds))
 
-	wg.Add(len(guilds))
+	p.waitGroup.Add(len(guilds))
 	for _, guild := range guilds {
 		go p.readGuildMessages(guild)
 	}
+	p.waitGroup.Wait()
 }
 
-func (p *DiscordPlugin) getDiscordReady() (err error) {
-	p.Session, err = discordgo.New(p.Token)
-	if err != nil {
-		return err
+// reportError sends the first error of the scan and cancels the scan of the other channels
+func (p *DiscordPlugin) reportError(err error) {
+	if errors.Is(err, context.Canceled) && errors.Is(p.ctx.Err(), context.Canceled) {
+		log.Warn().Msg("Discord scan canceled")
+		return
 	}
+	p.errOnce.Do(func() {
+		p.cancel()
+		p.errChan <- err
+	})
+}
+
+func (p *DiscordPlugin) getDiscordReady() error {
+	ctx, cancel := context.WithTimeout(p.ctx, discordReadyTimeout)
+	defer cancel()
 
-	p.Session.StateEnabled = true
-	ready := make(chan error)
-	p.Session.AddHandlerOnce(func(s *discordgo.Session, r *discordgo.Ready) {
-		ready <- nil
+	ready := make(chan struct{})
+	removeHandler := p.Session.AddHandlerOnce(func(s *discordgo.Session, r *discordgo.Ready) {
+		close(ready)
 	})
-	go func() {
-		err := p.Session.Open()
+	defer removeHandler()
+
+	if err := p.Session.Open(); err != nil {
+		return err
+	}
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error while waiting for discord session: %w", ctx.Err())
+	}
+}
+
+// setBaseUrl sends the requests of the client made to the Discord API to the plugin base URL,
+// the discordgo endpoints are shared by the process and are left unchanged
+func (p *DiscordPlugin) setBaseUrl(client *http.Client) error {
+	if p.BaseUrl == "" || strings.TrimSuffix(p.BaseUrl, "/")+"/" == discordgo.EndpointDiscord {
+		return nil
+	}
+	baseUrl, err := url.Parse(strings.TrimSuffix(p.BaseUrl, "/"))
+	if err != nil {
+		return fmt.Errorf("invalid discord base url: %w", err)
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &discordBaseUrlTransport{baseUrl: baseUrl, transport: transport}
+	return nil
+}
+
+type discordBaseUrlTransport struct {
+	baseUrl   *url.URL
+	transport http.RoundTripper
+}
+
+func (t *discordBaseUrlTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !strings.HasPrefix(request.URL.String(), discordgo.EndpointDiscord) {
+		return t.transport.RoundTrip(request)
+	}
+
+	redirected := request.Clone(request.Context())
+	redirected.URL.Scheme = t.baseUrl.Scheme
+	redirected.URL.Host = t.baseUrl.Host
+	redirected.URL.Path = t.baseUrl.Path + request.URL.Path
+	redirected.URL.RawPath = ""
+	redirected.Host = ""
+	return t.transport.RoundTrip(redirected)
+}
+
+// getDiscordRestReady fills the session state with the selected guilds and their channels
+// using the REST API only, instead of waiting for the Gateway Ready event
+func (p *DiscordPlugin) getDiscordRestReady() error {
+	user, err := p.Session.User("@me", discordgo.WithContext(p.ctx))
+	if err != nil {
+		return fmt.Errorf("error while getting discord user: %w", err)
+	}
+	p.State.User = user
+
+	afterID := ""
+	for {
+		userGuilds, err := p.Session.UserGuilds(discordGuildsPageSize, "", afterID, discordgo.WithContext(p.ctx))
 		if err != nil {
-			ready <- err
+			return fmt.Errorf("error while getting discord guilds: %w", err)
 		}
-	}()
-	time.AfterFunc(time.Second*10, func() {
-		ready <- fmt.Errorf("discord session timeout")
-	})
+		for _, userGuild := range userGuilds {
+			if !p.isSelectedGuild(userGuild.ID, userGuild.Name) {
+				continue
+			}
+			if err := p.addGuildToState(userGuild.ID, user); err != nil {
+				return fmt.Errorf("error while getting discord guild %s: %w", userGuild.Name, err)
+			}
+		}
+		if len(userGuilds) < discordGuildsPageSize {
+			return nil
+		}
+		afterID = userGuilds[len(userGuilds)-1].ID
+	}
+}
 
-	err = <-ready
+func (p *DiscordPlugin) addGuildToState(guildID string, user *discordgo.User) error {
+	guild, err := p.Session.Guild(guildID, discordgo.WithContext(p.ctx))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	guild.Channels, err = p.Session.GuildChannels(guildID, discordgo.WithContext(p.ctx))
+	if err != nil {
+		return err
+	}
+	for _, channel := range guild.Channels {
+		channel.GuildID = guildID
+	}
+
+	activeThreads, err := p.Session.GuildThreadsActive(guildID, discordgo.WithContext(p.ctx))
+	if err != nil {
+		return err
+	}
+	guild.Threads = activeThreads.Threads
+	if err := p.State.GuildAdd(guild); err != nil {
+		return err
+	}
+
+	// the member roles are needed to compute the channels permissions
+	member, err := p.Session.GuildMember(guildID, user.ID, discordgo.WithContext(p.ctx))
+	if err != nil {
+		return err
+	}
+	if member.User == nil {
+		member.User = user
+	}
+	return p.State.MemberAdd(member)
+}
+
+func (p *DiscordPlugin) isSelectedGuild(guildID, guildName string) bool {
+	for _, guild := range p.Guilds {
+		if guild == guildName || guild == guildID {
+			return true
+		}
+	}
+	return false
 }
 
 func (p *DiscordPlugin) getGuildsByNameOrIDs() []*discordgo.Guild {
 	var result []*discordgo.Guild
 
 	for _, guild := range p.Guilds {
-		for _, g := range p.Session.State.Guilds {
+		for _, g := range p.State.Guilds {
 			if g.Name == guild || g.ID == guild {
 				result = append(result, g)
 			}
@@ -208,40 +415,164 @@ func (p *DiscordPlugin) readChannelMessages(channel *discordgo.Channel) {
 	channelLogger := log.With().Str("guildID", channel.GuildID).Str("channel", channel.Name).Logger()
 	channelLogger.Debug().Send()
 
-	permission, err := p.Session.UserChannelPermissions(p.Session.State.User.ID, channel.ID)
+	permission, err := p.Session.UserChannelPermissions(p.State.User.ID, channel.ID, discordgo.WithContext(p.ctx))
 	if err != nil {
-		if err, ok := err.(*discordgo.RESTError); ok {
-			if err.Message.Code == 50001 {
-				channelLogger.Debug().Msg("No read permissions")
-				return
-			}
+		if isDiscordMissingAccess(err) {
+			channelLogger.Debug().Msg("No read permissions")
+			return
 		}
 
 		channelLogger.Error().Err(err).Msg("Failed to get permissions")
-		p.errChan <- err
+		p.reportError(err)
 		return
 	}
 	if permission&discordgo.PermissionViewChannel == 0 {
 		channelLogger.Debug().Msg("No read permissions")
 		return
 	}
-	if channel.Type != discordgo.ChannelTypeGuildText {
+	if !discordScannedChannelTypes[channel.Type] {
 		channelLogger.Debug().Msg("Not a text channel")
 		return
 	}
 
-	messages, err := p.getMessages(channel.ID, channelLogger)
+	messages := []*discordgo.Message{}
+	if channel.Type != discordgo.ChannelTypeGuildForum {
+		messages, err = p.getMessages(channel.ID, channelLogger)
+		if err != nil {
+			channelLogger.Error().Err(err).Msg("Failed to get messages")
+			p.reportError(err)
+			return
+		}
+	}
+
+	threads, err := p.getChannelThreads(channel)
 	if err != nil {
-		channelLogger.Error().Err(err).Msg("Failed to get messages")
-		p.errChan <- err
+		channelLogger.Error().Err(err).Msg("Failed to get threads")
+		p.reportError(err)
 		return
 	}
+	// threads started from a message are already scanned with the channel messages
+	scannedThreads := map[string]bool{}
+	for _, message := range messages {
+		scannedThreads[message.ChannelID] = true
+	}
+	for _, thread := range threads {
+		if scannedThreads[thread.ID] {
+			continue
+		}
+		threadMessages, err := p.getMessages(thread.ID, channelLogger.With().Str("thread", thread.Name).Logger())
+		if err != nil {
+			channelLogger.Error().Err(err).Msg("Failed to get thread messages")
+			p.reportError(err)
+			return
+		}
+		messages = append(messages, threadMessages...)
+	}
 	channelLogger.Info().Msgf("Found %d messages", len(messages))
 
 	items := convertMessagesToItems(channel.GuildID, &messages)
 	for _, item := range *items {
 		p.itemChan <- item
 	}
+
+	for _, message := range messages {
+		for _, attachment := range message.Attachments {
+			item, err := p.getAttachmentItem(channel.GuildID, message, attachment)
+			if errors.Is(err, errUnsupportedAttachment) {
+				channelLogger.Debug().Msgf("Skipping attachment %s: %s", attachment.Filename, err)
+				continue
+			}
+			if err != nil {
+				channelLogger.Warn().Msgf("Skipping attachment %s: %s", attachment.Filename, err)
+				continue
+			}
+			p.itemChan <- *item
+		}
+	}
+}
+
+// getChannelThreads returns the active threads of the channel and its public threads archived during the scanned period
+func (p *DiscordPlugin) getChannelThreads(channel *discordgo.Channel) ([]*discordgo.Channel, error) {
+	threads := []*discordgo.Channel{}
+	if guild, err := p.State.Guild(channel.GuildID); err == nil {
+		for _, thread := range guild.Threads {
+			if thread.ParentID == channel.ID {
+				threads = append(threads, thread)
+			}
+		}
+	}
+
+	var before *time.Time
+	for {
+		archived, err := p.Session.ThreadsArchived(channel.ID, before, discordThreadsPageSize, discordgo.WithContext(p.ctx))
+		if err != nil {
+			if isDiscordMissingAccess(err) {
+				return threads, nil
+			}
+			return nil, err
+		}
+		for _, thread := range archived.Threads {
+			if thread.ThreadMetadata == nil {
+				continue
+			}
+			archivedAt := thread.ThreadMetadata.ArchiveTimestamp
+			// archived threads are sorted by archive time, the next ones have no message in the scanned period
+			if p.BackwardDuration > 0 && time.Since(archivedAt) > p.BackwardDuration {
+				return threads, nil
+			}
+			threads = append(threads, thread)
+			before = &archivedAt
+		}
+		if !archived.HasMore || len(archived.Threads) == 0 {
+			return threads, nil
+		}
+	}
+}
+
+// getAttachmentItem downloads a text-like attachment of a message
+func (p *DiscordPlugin) getAttachmentItem(guildId string, message *discordgo.Message, attachment *discordgo.MessageAttachment) (*Item, error) {
+	if attachment.Size > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment size %d exceeds the limit of %d bytes", attachment.Size, maxAttachmentSize)
+	}
+	mediaType := strings.SplitN(attachment.ContentType, "/", 2)[0]
+	if mediaType == "image" || mediaType == "video" || mediaType == "audio" {
+		return nil, errUnsupportedAttachment
+	}
+
+	request, err := http.NewRequestWithContext(p.ctx, http.MethodGet, attachment.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating attachment request: %w", err)
+	}
+	response, err := p.HttpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error while downloading attachment: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error while downloading attachment: unexpected status %s", response.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, maxAttachmentSize))
+	if err != nil {
+		return nil, fmt.Errorf("error while downloading attachment: %w", err)
+	}
+
+	content, err := getAttachmentContent(attachment.Filename, attachment.ContentType, data)
+	if err != nil {
+		return nil, err
+	}
+	return &Item{
+		Content: content,
+		ID:      fmt.Sprintf("%s; Attachment: %s", getDiscordMessageLink(guildId, message), attachment.Filename),
+	}, nil
+}
+
+func isDiscordMissingAccess(err error) bool {
+	var restError *discordgo.RESTError
+	if !errors.As(err, &restError) || restError.Message == nil {
+		return false
+	}
+	return restError.Message.Code == discordMissingAccessCode || restError.Message.Code == discordMissingPermissionCode
 }
 
 func (p *DiscordPlugin) getMessages(channelID string, logger zerolog.Logger) ([]*discordgo.Message, error) {
@@ -250,13 +581,16 @@ func (p *DiscordPlugin) getMessages(channelID string, logger zerolog.Logger) ([]
 
 	var beforeID string
 
-	m, err := p.Session.ChannelMessages(channelID, 100, beforeID, "", "")
+	m, err := p.Session.ChannelMessages(channelID, discordMessagesPageSize, beforeID, "", "", discordgo.WithContext(p.ctx))
 	if err != nil {
 		return nil, err
 	}
 
 	lastMessage := false
 	for len(m) > 0 && !lastMessage {
+		if err := p.ctx.Err(); err != nil {
+			return nil, err
+		}
 
 		for _, message := range m {
 
@@ -286,7 +620,7 @@ func (p *DiscordPlugin) getMessages(channelID string, logger zerolog.Logger) ([]
 			beforeID = message.ID
 		}
 
-		m, err = p.Session.ChannelMessages(channelID, 100, beforeID, "", "")
+		m, err = p.Session.ChannelMessages(channelID, discordMessagesPageSize, beforeID, "", "", discordgo.WithContext(p.ctx))
 		if err != nil {
 			return nil, err
 		}
@@ -298,10 +632,45 @@ func (p *DiscordPlugin) getMessages(channelID string, logger zerolog.Logger) ([]
 func convertMessagesToItems(guildId string, messages *[]*discordgo.Message) *[]Item {
 	items := []Item{}
 	for _, message := range *messages {
+		content := getDiscordMessageContent(message)
+		if content == "" {
+			continue
+		}
 		items = append(items, Item{
-			Content: message.Content,
-			ID:      fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildId, message.ChannelID, message.ID),
+			Content: content,
+			ID:      getDiscordMessageLink(guildId, message),
 		})
 	}
 	return &items
 }
+
+func getDiscordMessageLink(guildId string, message *discordgo.Message) string {
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guildId, message.ChannelID, message.ID)
+}
+
+// getDiscordMessageContent returns the content of a message with the text of its embeds
+func getDiscordMessageContent(message *discordgo.Message) string {
+	texts := []string{message.Content}
+	for _, embed := range message.Embeds {
+		texts = append(texts, embed.Title, embed.URL, embed.Description)
+		if embed.Author != nil {
+			texts = append(texts, embed.Author.Name)
+		}
+		for _, field := range embed.Fields {
+			texts = append(texts, field.Name, field.Value)
+		}
+		if embed.Footer != nil {
+			texts = append(texts, embed.Footer.Text)
+		}
+	}
+
+	contents := []string{}
+	seen := map[string]bool{}
+	for _, text := range texts {
+		if text != "" && !seen[text] {
+			seen[text] = true
+			contents = append(contents, text)
+		}
+	}
+	return strings.Join(contents, "\n")
+}
@@ -0,0 +1,394 @@
+package plugins
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type fakeDiscordSession struct {
+	user        *discordgo.User
+	guilds      []*discordgo.Guild
+	messages    map[string][]*discordgo.Message
+	permissions int64
+	sendReady   bool
+	err         error
+
+	mu           sync.Mutex
+	readyHandler func(*discordgo.Session, *discordgo.Ready)
+	closed       bool
+}
+
+func (f *fakeDiscordSession) Open() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.sendReady && f.readyHandler != nil {
+		go f.readyHandler(nil, &discordgo.Ready{})
+	}
+	return nil
+}
+func (f *fakeDiscordSession) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	return nil
+}
+func (f *fakeDiscordSession) AddHandlerOnce(handler interface{}) func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readyHandler = handler.(func(*discordgo.Session, *discordgo.Ready))
+	return func() {}
+}
+func (f *fakeDiscordSession) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	return f.user, nil
+}
+func (f *fakeDiscordSession) UserGuilds(limit int, beforeID, afterID string, options ...discordgo.RequestOption) ([]*discordgo.UserGuild, error) {
+	userGuilds := []*discordgo.UserGuild{}
+	for _, guild := range f.guilds {
+		userGuilds = append(userGuilds, &discordgo.UserGuild{ID: guild.ID, Name: guild.Name})
+	}
+	return userGuilds, nil
+}
+func (f *fakeDiscordSession) UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error) {
+	return f.permissions, nil
+}
+func (f *fakeDiscordSession) Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	for _, guild := range f.guilds {
+		if guild.ID == guildID {
+			return &discordgo.Guild{ID: guild.ID, Name: guild.Name}, nil
+		}
+	}
+	return nil, errors.New("guild not found")
+}
+func (f *fakeDiscordSession) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	for _, guild := range f.guilds {
+		if guild.ID == guildID {
+			return guild.Channels, nil
+		}
+	}
+	return nil, errors.New("guild not found")
+}
+func (f *fakeDiscordSession) GuildThreadsActive(guildID string, options ...discordgo.RequestOption) (*discordgo.ThreadsList, error) {
+	return &discordgo.ThreadsList{}, nil
+}
+func (f *fakeDiscordSession) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	return &discordgo.Member{GuildID: guildID, User: f.user}, nil
+}
+func (f *fakeDiscordSession) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	messages := f.messages[channelID]
+	for i, message := range messages {
+		if message.ID == beforeID {
+			messages = messages[i+1:]
+			break
+		}
+	}
+	if beforeID != "" && len(messages) == len(f.messages[channelID]) {
+		return nil, nil
+	}
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
+	return messages, nil
+}
+func (f *fakeDiscordSession) ThreadsArchived(channelID string, before *time.Time, limit int, options ...discordgo.RequestOption) (*discordgo.ThreadsList, error) {
+	return &discordgo.ThreadsList{}, nil
+}
+
+func newFakeDiscordSession(messagesCount int) *fakeDiscordSession {
+	messages := []*discordgo.Message{}
+	for i := messagesCount; i > 0; i-- {
+		messages = append(messages, &discordgo.Message{
+			ID:        fmt.Sprintf("M%d", i),
+			ChannelID: "C1",
+			Content:   fmt.Sprintf("message %d", i),
+			Timestamp: time.Now().Add(-time.Duration(messagesCount-i) * time.Hour),
+		})
+	}
+	return &fakeDiscordSession{
+		user: &discordgo.User{ID: "U1"},
+		guilds: []*discordgo.Guild{{ID: "G1", Name: "guild1", Channels: []*discordgo.Channel{
+			{ID: "C1", GuildID: "G1", Name: "general", Type: discordgo.ChannelTypeGuildText},
+			{ID: "C2", GuildID: "G1", Name: "voice", Type: discordgo.ChannelTypeGuildVoice},
+		}}},
+		messages:    map[string][]*discordgo.Message{"C1": messages},
+		permissions: discordgo.PermissionViewChannel,
+		sendReady:   true,
+	}
+}
+
+// scanDiscord runs the plugin until the end of the scan and returns the items sorted by ID, as channels are scanned concurrently
+func scanDiscord(ctx context.Context, p *DiscordPlugin) ([]Item, error) {
+	itemsChan := make(chan Item)
+	errChan := make(chan error, 1)
+	go func() {
+		p.getItems(ctx, itemsChan, errChan)
+		close(itemsChan)
+	}()
+
+	items := []Item{}
+	for item := range itemsChan {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+
+	select {
+	case err := <-errChan:
+		return items, err
+	default:
+		return items, nil
+	}
+}
+
+func TestDiscordGetItems(t *testing.T) {
+	tests := []struct {
+		name             string
+		restOnly         bool
+		count            int
+		backwardDuration time.Duration
+		permissions      int64
+		expectedIDs      []string
+	}{
+		{
+			name:             "gateway session",
+			backwardDuration: time.Hour * 24,
+			permissions:      discordgo.PermissionViewChannel,
+			expectedIDs:      []string{"M1", "M2", "M3"},
+		},
+		{
+			name:             "rest only session",
+			restOnly:         true,
+			backwardDuration: time.Hour * 24,
+			permissions:      discordgo.PermissionViewChannel,
+			expectedIDs:      []string{"M1", "M2", "M3"},
+		},
+		{
+			name:             "messages count limit",
+			restOnly:         true,
+			count:            2,
+			backwardDuration: time.Hour * 24,
+			permissions:      discordgo.PermissionViewChannel,
+			expectedIDs:      []string{"M2", "M3"},
+		},
+		{
+			name:             "backward duration limit",
+			restOnly:         true,
+			backwardDuration: time.Hour + time.Minute,
+			permissions:      discordgo.PermissionViewChannel,
+			expectedIDs:      []string{"M2", "M3"},
+		},
+		{
+			name:             "no read permission",
+			restOnly:         true,
+			backwardDuration: time.Hour * 24,
+			expectedIDs:      []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newFakeDiscordSession(3)
+			session.permissions = tt.permissions
+			state := discordgo.NewState()
+			if !tt.restOnly {
+				// the gateway fills the state
+				state.User = session.user
+				for _, guild := range session.guilds {
+					if err := state.GuildAdd(guild); err != nil {
+						t.Fatalf("unexpected error: %v", err)
+					}
+				}
+			}
+
+			p := &DiscordPlugin{
+				Guilds:           []string{"guild1"},
+				Count:            tt.count,
+				BackwardDuration: tt.backwardDuration,
+				RestOnly:         tt.restOnly,
+				Session:          session,
+				State:            state,
+				HttpClient:       http.DefaultClient,
+			}
+			items, err := scanDiscord(context.Background(), p)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(items) != len(tt.expectedIDs) {
+				t.Fatalf("expected %d items, but got %d: %v", len(tt.expectedIDs), len(items), items)
+			}
+			for i, item := range items {
+				expectedID := "https://discord.com/channels/G1/C1/" + tt.expectedIDs[i]
+				if item.ID != expectedID {
+					t.Errorf("expected item %s, but got %s", expectedID, item.ID)
+				}
+			}
+			if !session.closed {
+				t.Errorf("expected the session to be closed")
+			}
+		})
+	}
+}
+
+func TestDiscordGetItemsError(t *testing.T) {
+	session := newFakeDiscordSession(3)
+	session.err = errors.New("some error")
+	p := &DiscordPlugin{
+		Guilds:           []string{"guild1"},
+		BackwardDuration: time.Hour,
+		RestOnly:         true,
+		Session:          session,
+		State:            discordgo.NewState(),
+	}
+
+	_, err := scanDiscord(context.Background(), p)
+	if err == nil || err.Error() != "some error" {
+		t.Errorf("expected error %v, but got %v", session.err, err)
+	}
+	if !session.closed {
+		t.Errorf("expected the session to be closed")
+	}
+}
+
+func TestDiscordGetItemsCanceled(t *testing.T) {
+	session := newFakeDiscordSession(3)
+	session.sendReady = false
+	p := &DiscordPlugin{
+		Guilds:           []string{"guild1"},
+		BackwardDuration: time.Hour,
+		Session:          session,
+		State:            discordgo.NewState(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	items, err := scanDiscord(ctx, p)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(items) != 0 {
+		t.Errorf("expected no items, but got %v", items)
+	}
+	if !session.closed {
+		t.Errorf("expected the session to be closed")
+	}
+}
+
+// newFakeDiscordApi serves the REST endpoints used by the plugin, for one guild with a text channel and a forum channel
+func newFakeDiscordApi(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	now := time.Now().Format(time.RFC3339)
+	var routes map[string]interface{}
+	var messages map[string][]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var response interface{}
+		switch {
+		case r.URL.Path == "/attachments/secret.txt":
+			_, _ = w.Write([]byte("API_KEY=123"))
+			return
+		case strings.HasSuffix(r.URL.Path, "/messages"):
+			response = []interface{}{}
+			if r.URL.Query().Get("before") == "" {
+				response = messages[strings.Split(r.URL.Path, "/")[4]]
+			}
+		default:
+			var ok bool
+			response, ok = routes[r.URL.Path]
+			if !ok {
+				t.Errorf("unexpected request %s", r.URL.Path)
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("error while encoding response: %v", err)
+		}
+	}))
+
+	routes = map[string]interface{}{
+		"/api/v9/users/@me":        map[string]interface{}{"id": "U1", "username": "scanner"},
+		"/api/v9/users/@me/guilds": []map[string]interface{}{{"id": "G1", "name": "guild1"}, {"id": "G2", "name": "guild2"}},
+		"/api/v9/guilds/G1":        map[string]interface{}{"id": "G1", "name": "guild1", "owner_id": "U0", "roles": []map[string]interface{}{{"id": "G1", "permissions": "1024"}}},
+		"/api/v9/guilds/G1/channels": []map[string]interface{}{
+			{"id": "C1", "name": "general", "type": 0},
+			{"id": "C2", "name": "voice", "type": 2},
+			{"id": "C3", "name": "forum", "type": 15},
+		},
+		"/api/v9/guilds/G1/members/U1":                map[string]interface{}{"user": map[string]interface{}{"id": "U1"}, "roles": []string{}},
+		"/api/v9/guilds/G1/threads/active":            map[string]interface{}{"threads": []map[string]interface{}{{"id": "T1", "name": "post", "parent_id": "C3", "type": 11}}},
+		"/api/v9/channels/C1/threads/archived/public": map[string]interface{}{"threads": []interface{}{}},
+		"/api/v9/channels/C3/threads/archived/public": map[string]interface{}{"threads": []map[string]interface{}{
+			{"id": "T2", "name": "old post", "parent_id": "C3", "type": 11, "thread_metadata": map[string]interface{}{"archived": true, "archive_timestamp": now}},
+			{"id": "T3", "name": "older post", "parent_id": "C3", "type": 11, "thread_metadata": map[string]interface{}{"archived": true, "archive_timestamp": "2020-01-01T00:00:00Z"}},
+		}},
+	}
+	messages = map[string][]map[string]interface{}{
+		"C1": {
+			{"id": "M2", "channel_id": "C1", "content": "password=123", "timestamp": now, "embeds": []map[string]interface{}{
+				{"title": "deploy", "fields": []map[string]interface{}{{"name": "token", "value": "abc"}}},
+			}},
+			{"id": "M1", "channel_id": "C1", "content": "", "timestamp": now, "attachments": []map[string]interface{}{
+				{"id": "A1", "filename": "secret.txt", "content_type": "text/plain", "size": 10, "url": server.URL + "/attachments/secret.txt"},
+				{"id": "A2", "filename": "image.png", "content_type": "image/png", "size": 10, "url": server.URL + "/attachments/image.png"},
+			}},
+		},
+		"T1": {{"id": "M3", "channel_id": "T1", "content": "forum post", "timestamp": now}},
+		"T2": {{"id": "M4", "channel_id": "T2", "content": "archived post", "timestamp": now}},
+	}
+
+	return server
+}
+
+func TestDiscordRestOnly(t *testing.T) {
+	server := newFakeDiscordApi(t)
+	defer server.Close()
+
+	p := &DiscordPlugin{
+		Token:            "Bot token",
+		Guilds:           []string{"guild1"},
+		BackwardDuration: time.Hour,
+		RestOnly:         true,
+		BaseUrl:          server.URL,
+	}
+
+	if err := p.newSession(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if discordgo.EndpointAPI != discordDefaultBaseUrl+"api/v"+discordgo.APIVersion+"/" {
+		t.Errorf("the discordgo endpoints were changed to %s", discordgo.EndpointAPI)
+	}
+
+	items, err := scanDiscord(context.Background(), p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Item{
+		{Content: "API_KEY=123", ID: "https://discord.com/channels/G1/C1/M1; Attachment: secret.txt"},
+		{Content: "password=123\ndeploy\ntoken\nabc", ID: "https://discord.com/channels/G1/C1/M2"},
+		{Content: "forum post", ID: "https://discord.com/channels/G1/T1/M3"},
+		{Content: "archived post", ID: "https://discord.com/channels/G1/T2/M4"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+	}
+}
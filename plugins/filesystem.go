@@ -52,10 +52,8 @@ func (p *FileSystemPlugin) getFiles(items chan Item, errs chan error, wg *sync.W
 		if err != nil {
 			log.Fatal().Err(err).Msg("error while walking through the directory")
 		}
-		for _, ignoredFolder := range ignoredFolders {
-			if fInfo.Name() == ignoredFolder && fInfo.IsDir() {
-				return filepath.SkipDir
-			}
+		if fInfo.IsDir() && isIgnoredFolder(fInfo.Name()) {
+			return filepath.SkipDir
 		}
 		if fInfo.Size() == 0 {
 			return nil
@@ -73,6 +71,15 @@ func (p *FileSystemPlugin) getFiles(items chan Item, errs chan error, wg *sync.W
 	p.getItems(items, errs, wg, fileList)
 }
 
+func isIgnoredFolder(name string) bool {
+	for _, ignoredFolder := range ignoredFolders {
+		if name == ignoredFolder {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *FileSystemPlugin) getItems(items chan Item, errs chan error, wg *sync.WaitGroup, fileList []string) {
 	for _, filePath := range fileList {
 		wg.Add(1)
@@ -0,0 +1,619 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	githubOrgFlag         = "org"
+	githubRepoFlag        = "repo"
+	githubTokenFlag       = "token"
+	githubBaseUrlFlag     = "base-url"
+	githubIssuesFlag      = "issues"
+	githubDiscussionsFlag = "discussions"
+	githubGistsFlag       = "gists"
+)
+
+const (
+	githubDefaultBaseUrl  = "https://api.github.com"
+	githubPageSize        = 100
+	githubMaxRepoRequests = 5
+)
+
+const (
+	githubTypeIssue             = "issue"
+	githubTypePullRequest       = "pullRequest"
+	githubTypeComment           = "comment"
+	githubTypeReviewComment     = "reviewComment"
+	githubTypeDiscussion        = "discussion"
+	githubTypeDiscussionComment = "discussionComment"
+	githubTypeGist              = "gist"
+	githubContentTypeKey        = "contentType"
+	githubRepositoryKey         = "repository"
+)
+
+var githubNextLinkRegex = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+type GithubPlugin struct {
+	Plugin
+	Channels
+
+	Org         string
+	Repos       []string
+	Token       string
+	BaseURL     string
+	Issues      bool
+	Discussions bool
+	Gists       bool
+}
+
+func (p *GithubPlugin) GetName() string {
+	return "github"
+}
+
+func (p *GithubPlugin) GetAuthorizationHeader() (string, error) {
+	if p.Token == "" {
+		return "", nil
+	}
+	return lib.CreateBearerAuthCredentials(p.Token), nil
+}
+
+func (p *GithubPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s ORG | --%s OWNER/REPO", p.GetName(), githubOrgFlag, githubRepoFlag),
+		Short: "Scan GitHub issues, pull requests, discussions and gists",
+		Long: "Scan GitHub or GitHub Enterprise issues, pull requests, comments, discussions and gists for sensitive information. " +
+			"The committed code and the wikis are git repositories, scan them with the git command.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if p.Org == "" && len(p.Repos) == 0 {
+				p.Errors <- fmt.Errorf("at least one of the flags in the group [%s %s] is required", githubOrgFlag, githubRepoFlag)
+				return
+			}
+			if err := p.initialize(); err != nil {
+				p.Errors <- fmt.Errorf("error while initializing github plugin: %w", err)
+				return
+			}
+			log.Info().Msg("GitHub plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Org, githubOrgFlag, "", "GitHub organization: scan all the repositories of the organization")
+	flags.StringArrayVar(&p.Repos, githubRepoFlag, []string{}, "GitHub repositories to scan, in the OWNER/REPO format")
+	flags.StringVar(&p.Token, githubTokenFlag, "", "GitHub personal access token, without it only the public content is scanned with a low rate limit")
+	flags.StringVar(&p.BaseURL, githubBaseUrlFlag, githubDefaultBaseUrl, "GitHub API base URL (example for GitHub Enterprise Server: https://github.company.com/api/v3)")
+	flags.BoolVar(&p.Issues, githubIssuesFlag, true, "Scan issues, pull requests and their comments")
+	flags.BoolVar(&p.Discussions, githubDiscussionsFlag, true, "Scan discussions and their comments, requires a token")
+	flags.BoolVar(&p.Gists, githubGistsFlag, false, "Scan the public gists of the organization members, or of the repositories owners")
+	command.MarkFlagsMutuallyExclusive(githubOrgFlag, githubRepoFlag)
+
+	return command, nil
+}
+
+func (p *GithubPlugin) initialize() error {
+	p.BaseURL = strings.TrimRight(p.BaseURL, "/")
+	for _, repo := range p.Repos {
+		if parts := strings.Split(repo, "/"); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid repository %s, expected format is OWNER/REPO", repo)
+		}
+	}
+
+	if p.Token == "" {
+		log.Warn().Msg("github token was not provided. The scan will be made anonymously only for the public content")
+		if p.Discussions {
+			log.Warn().Msg("Skipping discussions, the GitHub GraphQL API requires a token")
+			p.Discussions = false
+		}
+	}
+
+	p.Limit = make(chan struct{}, githubMaxRepoRequests)
+	return nil
+}
+
+func (p *GithubPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		repos, err := p.getRepos()
+		if err != nil {
+			p.Errors <- err
+			return
+		}
+		log.Info().Msgf("Found %d GitHub repositories", len(repos))
+
+		for _, repo := range repos {
+			p.WaitGroup.Add(1)
+			p.Limit <- struct{}{}
+			go func(repo GithubRepo) {
+				defer p.WaitGroup.Done()
+				if err := p.getRepoItems(repo); err != nil {
+					log.Warn().Msgf("Skipping the rest of repository %s: %s", repo.FullName, err)
+				}
+				<-p.Limit
+			}(repo)
+		}
+
+		if p.Gists {
+			users, err := p.getGistsUsers()
+			if err != nil {
+				p.Errors <- err
+				return
+			}
+			for _, user := range users {
+				if err := p.getGistsItems(user); err != nil {
+					log.Warn().Msgf("Skipping the rest of the gists of %s: %s", user, err)
+				}
+			}
+		}
+	}()
+}
+
+func (p *GithubPlugin) getRepos() ([]GithubRepo, error) {
+	repos := []GithubRepo{}
+	if p.Org != "" {
+		err := getGithubResults(p, fmt.Sprintf("%s/orgs/%s/repos?type=all&per_page=%d", p.BaseURL, p.Org, githubPageSize), func(page []GithubRepo) {
+			repos = append(repos, page...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error getting repositories of organization %s: %w", p.Org, err)
+		}
+		return repos, nil
+	}
+
+	for _, name := range p.Repos {
+		body, err := p.request(http.MethodGet, fmt.Sprintf("%s/repos/%s", p.BaseURL, name), nil)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error getting repository %s: %w", name, err)
+		}
+		repo := GithubRepo{}
+		if err := json.Unmarshal(body, &repo); err != nil {
+			return nil, fmt.Errorf("could not unmarshal response %w", err)
+		}
+		repos = append(repos, repo)
+	}
+	return repos, nil
+}
+
+func (p *GithubPlugin) getRepoItems(repo GithubRepo) error {
+	log.Info().Msgf("Getting items from repository %s", repo.FullName)
+	repoUrl := fmt.Sprintf("%s/repos/%s", p.BaseURL, repo.FullName)
+
+	if p.Issues {
+		if err := p.getIssuesItems(repo, repoUrl); err != nil {
+			return fmt.Errorf("unexpected error getting issues of repository %s: %w", repo.FullName, err)
+		}
+
+		err := getGithubResults(p, fmt.Sprintf("%s/issues/comments?per_page=%d", repoUrl, githubPageSize), func(comments []GithubComment) {
+			for _, comment := range comments {
+				p.sendItem(repo, githubTypeComment, comment.HtmlUrl, comment.Body)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unexpected error getting comments of repository %s: %w", repo.FullName, err)
+		}
+
+		err = getGithubResults(p, fmt.Sprintf("%s/pulls/comments?per_page=%d", repoUrl, githubPageSize), func(comments []GithubComment) {
+			for _, comment := range comments {
+				p.sendItem(repo, githubTypeReviewComment, comment.HtmlUrl, comment.Body)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unexpected error getting review comments of repository %s: %w", repo.FullName, err)
+		}
+	}
+
+	if p.Discussions && repo.HasDiscussions {
+		if err := p.getDiscussionsItems(repo); err != nil {
+			return fmt.Errorf("unexpected error getting discussions of repository %s: %w", repo.FullName, err)
+		}
+	}
+	return nil
+}
+
+// getIssuesItems sends the issues and pull requests, the issues endpoint returns both unless the issues are disabled
+func (p *GithubPlugin) getIssuesItems(repo GithubRepo, repoUrl string) error {
+	if !repo.HasIssues {
+		return getGithubResults(p, fmt.Sprintf("%s/pulls?state=all&per_page=%d", repoUrl, githubPageSize), func(pulls []GithubIssue) {
+			for _, pull := range pulls {
+				p.sendItem(repo, githubTypePullRequest, pull.HtmlUrl, pull.Title, pull.Body)
+			}
+		})
+	}
+
+	return getGithubResults(p, fmt.Sprintf("%s/issues?state=all&per_page=%d", repoUrl, githubPageSize), func(issues []GithubIssue) {
+		for _, issue := range issues {
+			contentType := githubTypeIssue
+			if issue.PullRequest != nil {
+				contentType = githubTypePullRequest
+			}
+			p.sendItem(repo, contentType, issue.HtmlUrl, issue.Title, issue.Body)
+		}
+	})
+}
+
+func (p *GithubPlugin) sendItem(repo GithubRepo, contentType string, id string, texts ...string) {
+	content := strings.TrimSpace(strings.Join(texts, "\n"))
+	if content == "" {
+		return
+	}
+	p.Items <- Item{
+		Content:  content,
+		ID:       id,
+		Metadata: map[string]string{githubContentTypeKey: contentType, githubRepositoryKey: repo.FullName},
+	}
+}
+
+// The discussions are only available in the GraphQL API. The first page of the comments and of their replies is requested
+// with the discussions, the next pages are requested from the discussion or the comment node.
+const (
+	githubDiscussionRepliesFirstPage  = `replies(first: 50) { pageInfo { hasNextPage endCursor } nodes { body url } }`
+	githubDiscussionCommentsFirstPage = `comments(first: 50) { pageInfo { hasNextPage endCursor } nodes { id body url ` + githubDiscussionRepliesFirstPage + ` } }`
+)
+
+const githubDiscussionsQuery = `query($owner: String!, $name: String!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    discussions(first: 50, after: $cursor) {
+      pageInfo { hasNextPage endCursor }
+      nodes { id title body url ` + githubDiscussionCommentsFirstPage + ` }
+    }
+  }
+}`
+
+const githubDiscussionCommentsQuery = `query($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on Discussion {
+      comments(first: 50, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { id body url ` + githubDiscussionRepliesFirstPage + ` }
+      }
+    }
+  }
+}`
+
+const githubDiscussionRepliesQuery = `query($id: ID!, $cursor: String) {
+  node(id: $id) {
+    ... on DiscussionComment {
+      replies(first: 50, after: $cursor) {
+        pageInfo { hasNextPage endCursor }
+        nodes { body url }
+      }
+    }
+  }
+}`
+
+func (p *GithubPlugin) getDiscussionsItems(repo GithubRepo) error {
+	owner, name, _ := strings.Cut(repo.FullName, "/")
+	variables := map[string]interface{}{"owner": owner, "name": name}
+
+	for {
+		response := GithubDiscussionsData{}
+		if err := p.graphqlRequest(githubDiscussionsQuery, variables, &response); err != nil {
+			return err
+		}
+
+		discussions := response.Repository.Discussions
+		for _, discussion := range discussions.Nodes {
+			p.sendItem(repo, githubTypeDiscussion, discussion.Url, discussion.Title, discussion.Body)
+			if err := p.getDiscussionCommentsItems(repo, discussion.ID, discussion.Comments); err != nil {
+				return fmt.Errorf("unexpected error getting comments of discussion %s: %w", discussion.Url, err)
+			}
+		}
+
+		if !discussions.PageInfo.HasNextPage {
+			return nil
+		}
+		variables["cursor"] = discussions.PageInfo.EndCursor
+	}
+}
+
+// getDiscussionCommentsItems sends the given page of comments and requests the next ones
+func (p *GithubPlugin) getDiscussionCommentsItems(repo GithubRepo, discussionId string, comments GithubDiscussionComments) error {
+	for {
+		for _, comment := range comments.Nodes {
+			p.sendItem(repo, githubTypeDiscussionComment, comment.Url, comment.Body)
+			if err := p.getDiscussionRepliesItems(repo, comment.ID, comment.Replies); err != nil {
+				return fmt.Errorf("unexpected error getting replies of comment %s: %w", comment.Url, err)
+			}
+		}
+
+		if !comments.PageInfo.HasNextPage {
+			return nil
+		}
+		response := GithubDiscussionNodeData{}
+		variables := map[string]interface{}{"id": discussionId, "cursor": comments.PageInfo.EndCursor}
+		if err := p.graphqlRequest(githubDiscussionCommentsQuery, variables, &response); err != nil {
+			return err
+		}
+		comments = response.Node.Comments
+	}
+}
+
+// getDiscussionRepliesItems sends the given page of replies and requests the next ones
+func (p *GithubPlugin) getDiscussionRepliesItems(repo GithubRepo, commentId string, replies GithubDiscussionReplies) error {
+	for {
+		for _, reply := range replies.Nodes {
+			p.sendItem(repo, githubTypeDiscussionComment, reply.Url, reply.Body)
+		}
+
+		if !replies.PageInfo.HasNextPage {
+			return nil
+		}
+		response := GithubDiscussionNodeData{}
+		variables := map[string]interface{}{"id": commentId, "cursor": replies.PageInfo.EndCursor}
+		if err := p.graphqlRequest(githubDiscussionRepliesQuery, variables, &response); err != nil {
+			return err
+		}
+		replies = response.Node.Replies
+	}
+}
+
+// graphqlRequest sends the query and unmarshals the data of the response
+func (p *GithubPlugin) graphqlRequest(query string, variables map[string]interface{}, data interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	body, err = p.request(http.MethodPost, getGithubGraphqlUrl(p.BaseURL), body)
+	if err != nil {
+		return err
+	}
+
+	response := GithubGraphqlResponse{Data: data}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("could not unmarshal response %w", err)
+	}
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("graphql error: %s", response.Errors[0].Message)
+	}
+	return nil
+}
+
+// getGithubGraphqlUrl returns the GraphQL endpoint, which is /api/graphql instead of /api/v3 on GitHub Enterprise Server
+func getGithubGraphqlUrl(baseUrl string) string {
+	if strings.HasSuffix(baseUrl, "/v3") {
+		return strings.TrimSuffix(baseUrl, "/v3") + "/graphql"
+	}
+	return baseUrl + "/graphql"
+}
+
+func (p *GithubPlugin) getGistsUsers() ([]string, error) {
+	if p.Org == "" {
+		users := []string{}
+		owners := map[string]bool{}
+		for _, repo := range p.Repos {
+			owner, _, _ := strings.Cut(repo, "/")
+			if !owners[owner] {
+				owners[owner] = true
+				users = append(users, owner)
+			}
+		}
+		return users, nil
+	}
+
+	users := []string{}
+	err := getGithubResults(p, fmt.Sprintf("%s/orgs/%s/members?per_page=%d", p.BaseURL, p.Org, githubPageSize), func(members []GithubUser) {
+		for _, member := range members {
+			users = append(users, member.Login)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error getting members of organization %s: %w", p.Org, err)
+	}
+	return users, nil
+}
+
+func (p *GithubPlugin) getGistsItems(user string) error {
+	gists := []GithubGist{}
+	err := getGithubResults(p, fmt.Sprintf("%s/users/%s/gists?per_page=%d", p.BaseURL, user, githubPageSize), func(page []GithubGist) {
+		gists = append(gists, page...)
+	})
+	if err != nil {
+		return fmt.Errorf("unexpected error getting gists of user %s: %w", user, err)
+	}
+
+	for _, gist := range gists {
+		metadata := map[string]string{githubContentTypeKey: githubTypeGist}
+		if gist.Description != "" {
+			p.Items <- Item{Content: gist.Description, ID: gist.HtmlUrl, Metadata: metadata}
+		}
+
+		for _, file := range gist.Files {
+			if file.Size > maxAttachmentSize {
+				log.Warn().Msgf("Skipping file %s of gist %s: size %d exceeds the limit of %d bytes", file.Filename, gist.HtmlUrl, file.Size, maxAttachmentSize)
+				continue
+			}
+			data, err := p.request(http.MethodGet, file.RawUrl, nil)
+			if err != nil {
+				return fmt.Errorf("unexpected error downloading file %s of gist %s: %w", file.Filename, gist.HtmlUrl, err)
+			}
+			content, err := getAttachmentContent(file.Filename, file.Type, data)
+			if err != nil {
+				log.Warn().Msgf("Skipping file %s of gist %s: %s", file.Filename, gist.HtmlUrl, err)
+				continue
+			}
+			p.Items <- Item{Content: content, ID: fmt.Sprintf("%s; File: %s", gist.HtmlUrl, file.Filename), Metadata: metadata}
+		}
+
+		if gist.Comments == 0 {
+			continue
+		}
+		err := getGithubResults(p, fmt.Sprintf("%s/gists/%s/comments?per_page=%d", p.BaseURL, gist.ID, githubPageSize), func(comments []GithubComment) {
+			for _, comment := range comments {
+				p.Items <- Item{Content: comment.Body, ID: fmt.Sprintf("%s#gistcomment-%d", gist.HtmlUrl, comment.ID), Metadata: metadata}
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unexpected error getting comments of gist %s: %w", gist.HtmlUrl, err)
+		}
+	}
+	return nil
+}
+
+// getGithubResults requests the given url and then follows the next links of the Link header, calling handle with the results of each page
+func getGithubResults[T any](p *GithubPlugin, url string, handle func(results []T)) error {
+	for url != "" {
+		body, response, err := p.requestWithResponse(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		results := []T{}
+		if err := json.Unmarshal(body, &results); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+		handle(results)
+		url = getGithubNextLink(response.Header.Get("Link"))
+	}
+	return nil
+}
+
+func getGithubNextLink(link string) string {
+	match := githubNextLinkRegex.FindStringSubmatch(link)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func (p *GithubPlugin) request(method string, url string, body []byte) ([]byte, error) {
+	data, _, err := p.requestWithResponse(method, url, body)
+	return data, err
+}
+
+// requestWithResponse retries the requests rejected by the primary or secondary rate limits, after the delay given by GitHub
+// https://docs.github.com/en/rest/using-the-rest-api/rate-limits-for-the-rest-api
+func (p *GithubPlugin) requestWithResponse(method string, url string, body []byte) ([]byte, *http.Response, error) {
+	return lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		if body == nil {
+			return lib.HttpRequest(method, url, p)
+		}
+		return lib.HttpJsonRequest(method, url, body, p)
+	}, getGithubRetryDelay)
+}
+
+func getGithubRetryDelay(response *http.Response, attempt int) (time.Duration, bool) {
+	if response.StatusCode != http.StatusForbidden && response.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	if response.Header.Get("Retry-After") != "" {
+		return lib.RetryAfterDelay(response, attempt)
+	}
+	if response.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(response.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err != nil {
+			return time.Minute, true
+		}
+		delay := time.Until(time.Unix(reset, 0))
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+type GithubRepo struct {
+	FullName       string `json:"full_name"`
+	HasIssues      bool   `json:"has_issues"`
+	HasDiscussions bool   `json:"has_discussions"`
+}
+
+type GithubUser struct {
+	Login string `json:"login"`
+}
+
+type GithubIssue struct {
+	Number      int       `json:"number"`
+	Title       string    `json:"title"`
+	Body        string    `json:"body"`
+	HtmlUrl     string    `json:"html_url"`
+	PullRequest *struct{} `json:"pull_request"`
+}
+
+type GithubComment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HtmlUrl string `json:"html_url"`
+}
+
+type GithubGist struct {
+	ID          string                    `json:"id"`
+	Description string                    `json:"description"`
+	HtmlUrl     string                    `json:"html_url"`
+	Comments    int                       `json:"comments"`
+	Files       map[string]GithubGistFile `json:"files"`
+}
+
+type GithubGistFile struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	RawUrl   string `json:"raw_url"`
+}
+
+type GithubPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type GithubGraphqlResponse struct {
+	Data   interface{} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type GithubDiscussionsData struct {
+	Repository struct {
+		Discussions struct {
+			PageInfo GithubPageInfo `json:"pageInfo"`
+			Nodes    []struct {
+				ID       string                   `json:"id"`
+				Title    string                   `json:"title"`
+				Body     string                   `json:"body"`
+				Url      string                   `json:"url"`
+				Comments GithubDiscussionComments `json:"comments"`
+			} `json:"nodes"`
+		} `json:"discussions"`
+	} `json:"repository"`
+}
+
+// GithubDiscussionNodeData is the data of the comments or the replies queries
+type GithubDiscussionNodeData struct {
+	Node struct {
+		Comments GithubDiscussionComments `json:"comments"`
+		Replies  GithubDiscussionReplies  `json:"replies"`
+	} `json:"node"`
+}
+
+type GithubDiscussionComments struct {
+	PageInfo GithubPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		ID      string                  `json:"id"`
+		Body    string                  `json:"body"`
+		Url     string                  `json:"url"`
+		Replies GithubDiscussionReplies `json:"replies"`
+	} `json:"nodes"`
+}
+
+type GithubDiscussionReplies struct {
+	PageInfo GithubPageInfo `json:"pageInfo"`
+	Nodes    []struct {
+		Body string `json:"body"`
+		Url  string `json:"url"`
+	} `json:"nodes"`
+}
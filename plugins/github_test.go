@@ -0,0 +1,168 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGetGithubNextLink(t *testing.T) {
+	link := `<https://api.github.com/repositories/1/issues?page=2>; rel="next", <https://api.github.com/repositories/1/issues?page=5>; rel="last"`
+
+	if next := getGithubNextLink(link); next != "https://api.github.com/repositories/1/issues?page=2" {
+		t.Errorf("unexpected next link %s", next)
+	}
+	if next := getGithubNextLink(`<https://api.github.com/repositories/1/issues?page=1>; rel="prev"`); next != "" {
+		t.Errorf("expected no next link, but got %s", next)
+	}
+}
+
+func TestGetGithubGraphqlUrl(t *testing.T) {
+	tests := map[string]string{
+		"https://api.github.com":            "https://api.github.com/graphql",
+		"https://github.company.com/api/v3": "https://github.company.com/api/graphql",
+	}
+	for baseUrl, expected := range tests {
+		if graphqlUrl := getGithubGraphqlUrl(baseUrl); graphqlUrl != expected {
+			t.Errorf("expected graphql url %s for %s, but got %s", expected, baseUrl, graphqlUrl)
+		}
+	}
+}
+
+func TestGithubGetItems(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var response interface{}
+		switch r.URL.Path {
+		case "/api/v3/repos/octo/app":
+			response = map[string]interface{}{"full_name": "octo/app", "has_issues": true, "has_discussions": true}
+		case "/api/v3/repos/octo/app/issues":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<%s/api/v3/repos/octo/app/issues?page=2>; rel="next"`, server.URL))
+				response = []map[string]interface{}{{"title": "leak", "body": "password=123", "html_url": "https://github.com/octo/app/issues/1"}}
+			} else {
+				response = []map[string]interface{}{{"title": "fix", "body": "", "html_url": "https://github.com/octo/app/pull/2", "pull_request": map[string]string{}}}
+			}
+		case "/api/v3/repos/octo/app/issues/comments":
+			response = []map[string]interface{}{{"id": 10, "body": "token=abc", "html_url": "https://github.com/octo/app/issues/1#issuecomment-10"}}
+		case "/api/v3/repos/octo/app/pulls/comments":
+			response = []map[string]interface{}{{"id": 11, "body": "remove this key", "html_url": "https://github.com/octo/app/pull/2#discussion_r11"}}
+		case "/api/v3/repos/octo/broken":
+			response = map[string]interface{}{"full_name": "octo/broken", "has_issues": true}
+		case "/api/v3/repos/octo/broken/issues":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/api/graphql":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPost {
+				t.Errorf("unexpected graphql request %s %s", r.Method, body)
+			}
+			switch {
+			case strings.Contains(string(body), `"owner":"octo"`):
+				_, _ = w.Write([]byte(`{"data": {"repository": {"discussions": {"pageInfo": {"hasNextPage": false}, "nodes": [
+					{"id": "D_3", "title": "help", "body": "my secret", "url": "https://github.com/octo/app/discussions/3",
+					 "comments": {"pageInfo": {"hasNextPage": true, "endCursor": "C1"}, "nodes": [
+					   {"id": "DC_1", "body": "answer", "url": "https://github.com/octo/app/discussions/3#discussioncomment-1",
+					    "replies": {"pageInfo": {"hasNextPage": true, "endCursor": "R1"}, "nodes": [{"body": "thanks", "url": "https://github.com/octo/app/discussions/3#discussioncomment-2"}]}}]}}
+				]}}}}`))
+			case strings.Contains(string(body), `"id":"D_3"`) && strings.Contains(string(body), `"cursor":"C1"`):
+				_, _ = w.Write([]byte(`{"data": {"node": {"comments": {"pageInfo": {"hasNextPage": false}, "nodes": [
+					{"id": "DC_4", "body": "key=456", "url": "https://github.com/octo/app/discussions/3#discussioncomment-4", "replies": {"pageInfo": {"hasNextPage": false}}}
+				]}}}}`))
+			case strings.Contains(string(body), `"id":"DC_1"`) && strings.Contains(string(body), `"cursor":"R1"`):
+				_, _ = w.Write([]byte(`{"data": {"node": {"replies": {"pageInfo": {"hasNextPage": false}, "nodes": [
+					{"body": "token=xyz", "url": "https://github.com/octo/app/discussions/3#discussioncomment-3"}
+				]}}}}`))
+			default:
+				t.Errorf("unexpected graphql request %s", body)
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			return
+		case "/api/v3/users/octo/gists":
+			response = []map[string]interface{}{{
+				"id": "g1", "description": "", "html_url": "https://gist.github.com/octo/g1", "comments": 1,
+				"files": map[string]interface{}{"config.env": map[string]interface{}{"filename": "config.env", "type": "text/plain", "size": 10, "raw_url": server.URL + "/raw/g1/config.env"}},
+			}}
+		case "/raw/g1/config.env":
+			_, _ = w.Write([]byte("API_KEY=123"))
+			return
+		case "/api/v3/gists/g1/comments":
+			response = []map[string]interface{}{{"id": 5, "body": "nice"}}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("error while encoding response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	p := &GithubPlugin{
+		Channels:    Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}},
+		Repos:       []string{"octo/app", "octo/broken"},
+		Token:       "token",
+		BaseURL:     server.URL + "/api/v3/",
+		Issues:      true,
+		Discussions: true,
+		Gists:       true,
+	}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	// repositories and gists are scanned concurrently
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	expected := []Item{
+		{Content: "nice", ID: "https://gist.github.com/octo/g1#gistcomment-5", Metadata: map[string]string{githubContentTypeKey: githubTypeGist}},
+		{Content: "API_KEY=123", ID: "https://gist.github.com/octo/g1; File: config.env", Metadata: map[string]string{githubContentTypeKey: githubTypeGist}},
+		{Content: "help\nmy secret", ID: "https://github.com/octo/app/discussions/3", Metadata: map[string]string{githubContentTypeKey: githubTypeDiscussion}},
+		{Content: "answer", ID: "https://github.com/octo/app/discussions/3#discussioncomment-1", Metadata: map[string]string{githubContentTypeKey: githubTypeDiscussionComment}},
+		{Content: "thanks", ID: "https://github.com/octo/app/discussions/3#discussioncomment-2", Metadata: map[string]string{githubContentTypeKey: githubTypeDiscussionComment}},
+		{Content: "token=xyz", ID: "https://github.com/octo/app/discussions/3#discussioncomment-3", Metadata: map[string]string{githubContentTypeKey: githubTypeDiscussionComment}},
+		{Content: "key=456", ID: "https://github.com/octo/app/discussions/3#discussioncomment-4", Metadata: map[string]string{githubContentTypeKey: githubTypeDiscussionComment}},
+		{Content: "leak\npassword=123", ID: "https://github.com/octo/app/issues/1", Metadata: map[string]string{githubContentTypeKey: githubTypeIssue}},
+		{Content: "token=abc", ID: "https://github.com/octo/app/issues/1#issuecomment-10", Metadata: map[string]string{githubContentTypeKey: githubTypeComment}},
+		{Content: "fix", ID: "https://github.com/octo/app/pull/2", Metadata: map[string]string{githubContentTypeKey: githubTypePullRequest}},
+		{Content: "remove this key", ID: "https://github.com/octo/app/pull/2#discussion_r11", Metadata: map[string]string{githubContentTypeKey: githubTypeReviewComment}},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID || item.Metadata[githubContentTypeKey] != expected[i].Metadata[githubContentTypeKey] {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+		if item.Metadata[githubContentTypeKey] != githubTypeGist && item.Metadata[githubRepositoryKey] != "octo/app" {
+			t.Errorf("expected repository octo/app, but got %v", item.Metadata)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	gitlabGroupFlag         = "group"
+	gitlabProjectFlag       = "project"
+	gitlabTokenFlag         = "token"
+	gitlabBaseUrlFlag       = "base-url"
+	gitlabIssuesFlag        = "issues"
+	gitlabMergeRequestsFlag = "merge-requests"
+	gitlabSnippetsFlag      = "snippets"
+	gitlabWikisFlag         = "wikis"
+)
+
+const (
+	gitlabDefaultBaseUrl     = "https://gitlab.com"
+	gitlabPageSize           = 100
+	gitlabMaxProjectRequests = 5
+	// default branch of the snippets repositories
+	gitlabSnippetDefaultRef = "main"
+)
+
+const (
+	gitlabTypeIssue            = "issue"
+	gitlabTypeIssueNote        = "issueNote"
+	gitlabTypeMergeRequest     = "mergeRequest"
+	gitlabTypeMergeRequestNote = "mergeRequestNote"
+	gitlabTypeSnippet          = "snippet"
+	gitlabTypeWiki             = "wiki"
+	gitlabContentTypeKey       = "contentType"
+	gitlabProjectKey           = "project"
+)
+
+type GitlabPlugin struct {
+	Plugin
+	Channels
+
+	Group         string
+	Projects      []string
+	Token         string
+	BaseURL       string
+	Issues        bool
+	MergeRequests bool
+	Snippets      bool
+	Wikis         bool
+
+	apiUrl string
+}
+
+func (p *GitlabPlugin) GetName() string {
+	return "gitlab"
+}
+
+func (p *GitlabPlugin) GetAuthorizationHeader() (string, error) {
+	if p.Token == "" {
+		return "", nil
+	}
+	return lib.CreateBearerAuthCredentials(p.Token), nil
+}
+
+func (p *GitlabPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s GROUP | --%s PROJECT", p.GetName(), gitlabGroupFlag, gitlabProjectFlag),
+		Short: "Scan GitLab issues, merge requests, snippets and wikis",
+		Long:  "Scan GitLab.com or self-managed GitLab issues, merge requests, their notes, project snippets and wiki pages for sensitive information",
+		Run: func(cmd *cobra.Command, args []string) {
+			if p.Group == "" && len(p.Projects) == 0 {
+				p.Errors <- fmt.Errorf("at least one of the flags in the group [%s %s] is required", gitlabGroupFlag, gitlabProjectFlag)
+				return
+			}
+			p.initialize()
+			log.Info().Msg("GitLab plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Group, gitlabGroupFlag, "", "GitLab group ID or full path: scan all the projects of the group and its subgroups")
+	flags.StringArrayVar(&p.Projects, gitlabProjectFlag, []string{}, "GitLab projects IDs or full paths (example: group/subgroup/project)")
+	flags.StringVar(&p.Token, gitlabTokenFlag, "", "GitLab personal, group or project access token with the read_api scope, without it only the public content is scanned")
+	flags.StringVar(&p.BaseURL, gitlabBaseUrlFlag, gitlabDefaultBaseUrl, "GitLab instance URL, for self-managed instances")
+	flags.BoolVar(&p.Issues, gitlabIssuesFlag, true, "Scan issues and their notes")
+	flags.BoolVar(&p.MergeRequests, gitlabMergeRequestsFlag, true, "Scan merge requests and their notes")
+	flags.BoolVar(&p.Snippets, gitlabSnippetsFlag, true, "Scan project snippets")
+	flags.BoolVar(&p.Wikis, gitlabWikisFlag, true, "Scan wiki pages")
+	command.MarkFlagsMutuallyExclusive(gitlabGroupFlag, gitlabProjectFlag)
+
+	return command, nil
+}
+
+func (p *GitlabPlugin) initialize() {
+	p.BaseURL = strings.TrimRight(p.BaseURL, "/")
+	p.apiUrl = p.BaseURL + "/api/v4"
+	if p.Token == "" {
+		log.Warn().Msg("gitlab token was not provided. The scan will be made anonymously only for the public content")
+	}
+	p.Limit = make(chan struct{}, gitlabMaxProjectRequests)
+}
+
+func (p *GitlabPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		projects, err := p.getProjects()
+		if err != nil {
+			p.Errors <- err
+			return
+		}
+		log.Info().Msgf("Found %d GitLab projects", len(projects))
+
+		for _, project := range projects {
+			p.WaitGroup.Add(1)
+			p.Limit <- struct{}{}
+			go func(project GitlabProject) {
+				defer p.WaitGroup.Done()
+				defer func() { <-p.Limit }()
+				if err := p.getProjectItems(project); err != nil {
+					log.Warn().Msgf("Skipping the rest of project %s: %s", project.PathWithNamespace, err)
+				}
+			}(project)
+		}
+	}()
+}
+
+func (p *GitlabPlugin) getProjects() ([]GitlabProject, error) {
+	projects := []GitlabProject{}
+	if p.Group != "" {
+		groupUrl := fmt.Sprintf("%s/groups/%s/projects?include_subgroups=true", p.apiUrl, url.PathEscape(p.Group))
+		err := getGitlabResults(p, groupUrl, func(page []GitlabProject) {
+			projects = append(projects, page...)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error getting projects of group %s: %w", p.Group, err)
+		}
+		return projects, nil
+	}
+
+	for _, name := range p.Projects {
+		body, _, err := p.request(fmt.Sprintf("%s/projects/%s", p.apiUrl, url.PathEscape(name)))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error getting project %s: %w", name, err)
+		}
+		project := GitlabProject{}
+		if err := json.Unmarshal(body, &project); err != nil {
+			return nil, fmt.Errorf("could not unmarshal response %w", err)
+		}
+		projects = append(projects, project)
+	}
+	return projects, nil
+}
+
+func (p *GitlabPlugin) getProjectItems(project GitlabProject) error {
+	log.Info().Msgf("Getting items from project %s", project.PathWithNamespace)
+	projectUrl := fmt.Sprintf("%s/projects/%d", p.apiUrl, project.ID)
+
+	if p.Issues && project.IssuesEnabled {
+		if err := p.getNoteableItems(project, projectUrl+"/issues", gitlabTypeIssue, gitlabTypeIssueNote); err != nil {
+			return fmt.Errorf("unexpected error getting issues of project %s: %w", project.PathWithNamespace, err)
+		}
+	}
+	if p.MergeRequests && project.MergeRequestsEnabled {
+		if err := p.getNoteableItems(project, projectUrl+"/merge_requests", gitlabTypeMergeRequest, gitlabTypeMergeRequestNote); err != nil {
+			return fmt.Errorf("unexpected error getting merge requests of project %s: %w", project.PathWithNamespace, err)
+		}
+	}
+	if p.Snippets && project.SnippetsEnabled {
+		if err := p.getSnippetsItems(project, projectUrl); err != nil {
+			return fmt.Errorf("unexpected error getting snippets of project %s: %w", project.PathWithNamespace, err)
+		}
+	}
+	if p.Wikis && project.WikiEnabled {
+		if err := p.getWikiItems(project, projectUrl); err != nil {
+			return fmt.Errorf("unexpected error getting wiki of project %s: %w", project.PathWithNamespace, err)
+		}
+	}
+	return nil
+}
+
+// getNoteableItems sends the issues or merge requests listed by the given url, followed by their notes
+func (p *GitlabPlugin) getNoteableItems(project GitlabProject, listUrl string, contentType string, noteContentType string) error {
+	noteables := []GitlabNoteable{}
+	err := getGitlabResults(p, listUrl+"?state=all", func(page []GitlabNoteable) {
+		noteables = append(noteables, page...)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, noteable := range noteables {
+		p.sendItem(project, contentType, noteable.WebUrl, noteable.Title, noteable.Description)
+
+		notesUrl := fmt.Sprintf("%s/%d/notes", listUrl, noteable.IID)
+		err := getGitlabResults(p, notesUrl, func(notes []GitlabNote) {
+			for _, note := range notes {
+				// system notes are generated by GitLab, like "changed the description"
+				if note.System {
+					continue
+				}
+				p.sendItem(project, noteContentType, fmt.Sprintf("%s#note_%d", noteable.WebUrl, note.ID), note.Body)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GitlabPlugin) getSnippetsItems(project GitlabProject, projectUrl string) error {
+	snippets := []GitlabSnippet{}
+	err := getGitlabResults(p, projectUrl+"/snippets", func(page []GitlabSnippet) {
+		snippets = append(snippets, page...)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, snippet := range snippets {
+		p.sendItem(project, gitlabTypeSnippet, snippet.WebUrl, snippet.Title, snippet.Description)
+
+		files := snippet.Files
+		if len(files) == 0 {
+			files = []GitlabSnippetFile{{Path: snippet.FileName}}
+		}
+		for _, file := range files {
+			data, _, err := p.request(getGitlabSnippetFileUrl(projectUrl, snippet, file))
+			if err != nil {
+				return fmt.Errorf("unexpected error downloading file %s of snippet %s: %w", file.Path, snippet.WebUrl, err)
+			}
+			content, err := getAttachmentContent(file.Path, "", data)
+			if err != nil {
+				log.Warn().Msgf("Skipping file %s of snippet %s: %s", file.Path, snippet.WebUrl, err)
+				continue
+			}
+			p.sendItem(project, gitlabTypeSnippet, fmt.Sprintf("%s; File: %s", snippet.WebUrl, file.Path), content)
+		}
+	}
+	return nil
+}
+
+// getGitlabSnippetFileUrl returns the API endpoint of the file, the raw_url of the web interface doesn't accept API tokens
+func getGitlabSnippetFileUrl(projectUrl string, snippet GitlabSnippet, file GitlabSnippetFile) string {
+	if file.RawUrl == "" {
+		// snippets of GitLab versions without multiple files
+		return fmt.Sprintf("%s/snippets/%d/raw", projectUrl, snippet.ID)
+	}
+
+	// the raw_url is <web_url>/raw/<ref>/<path>
+	ref := gitlabSnippetDefaultRef
+	if refAndPath, found := strings.CutPrefix(file.RawUrl, snippet.WebUrl+"/raw/"); found {
+		if rawRef, found := strings.CutSuffix(refAndPath, "/"+file.Path); found && rawRef != "" {
+			ref = rawRef
+		}
+	}
+	return fmt.Sprintf("%s/snippets/%d/files/%s/%s/raw", projectUrl, snippet.ID, url.PathEscape(ref), url.PathEscape(file.Path))
+}
+
+func (p *GitlabPlugin) getWikiItems(project GitlabProject, projectUrl string) error {
+	// the wiki pages endpoint is not paginated
+	body, _, err := p.request(projectUrl + "/wikis?with_content=1")
+	if err != nil {
+		return err
+	}
+	pages := []GitlabWikiPage{}
+	if err := json.Unmarshal(body, &pages); err != nil {
+		return fmt.Errorf("could not unmarshal response %w", err)
+	}
+
+	for _, page := range pages {
+		p.sendItem(project, gitlabTypeWiki, fmt.Sprintf("%s/-/wikis/%s", project.WebUrl, page.Slug), page.Title, page.Content)
+	}
+	return nil
+}
+
+func (p *GitlabPlugin) sendItem(project GitlabProject, contentType string, id string, texts ...string) {
+	content := strings.TrimSpace(strings.Join(texts, "\n"))
+	if content == "" {
+		return
+	}
+	p.Items <- Item{
+		Content:  content,
+		ID:       id,
+		Metadata: map[string]string{gitlabContentTypeKey: contentType, gitlabProjectKey: project.PathWithNamespace},
+	}
+}
+
+// getGitlabResults requests the pages of the given url until the X-Next-Page header is empty, calling handle with the results of each page
+func getGitlabResults[T any](p *GitlabPlugin, listUrl string, handle func(results []T)) error {
+	separator := "?"
+	if strings.Contains(listUrl, "?") {
+		separator = "&"
+	}
+
+	for page := "1"; page != ""; {
+		body, response, err := p.request(fmt.Sprintf("%s%sper_page=%d&page=%s", listUrl, separator, gitlabPageSize, page))
+		if err != nil {
+			return err
+		}
+
+		results := []T{}
+		if err := json.Unmarshal(body, &results); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+		handle(results)
+		page = response.Header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+// request retries the requests rejected by the rate limits after the Retry-After delay
+// https://docs.gitlab.com/ee/security/rate_limits.html
+func (p *GitlabPlugin) request(url string) ([]byte, *http.Response, error) {
+	return lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		return lib.HttpRequest(http.MethodGet, url, p)
+	}, lib.RetryAfterDelay)
+}
+
+type GitlabProject struct {
+	ID                   int    `json:"id"`
+	PathWithNamespace    string `json:"path_with_namespace"`
+	WebUrl               string `json:"web_url"`
+	IssuesEnabled        bool   `json:"issues_enabled"`
+	MergeRequestsEnabled bool   `json:"merge_requests_enabled"`
+	SnippetsEnabled      bool   `json:"snippets_enabled"`
+	WikiEnabled          bool   `json:"wiki_enabled"`
+}
+
+// GitlabNoteable is an issue or a merge request
+type GitlabNoteable struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	WebUrl      string `json:"web_url"`
+}
+
+type GitlabNote struct {
+	ID     int    `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+type GitlabSnippet struct {
+	ID          int                 `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	FileName    string              `json:"file_name"`
+	WebUrl      string              `json:"web_url"`
+	Files       []GitlabSnippetFile `json:"files"`
+}
+
+type GitlabSnippetFile struct {
+	Path   string `json:"path"`
+	RawUrl string `json:"raw_url"`
+}
+
+type GitlabWikiPage struct {
+	Slug    string `json:"slug"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+}
@@ -0,0 +1,146 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestGetGitlabSnippetFileUrl(t *testing.T) {
+	projectUrl := "https://gitlab.com/api/v4/projects/7"
+	snippet := GitlabSnippet{ID: 6, WebUrl: "https://gitlab.com/group/app/-/snippets/6"}
+	tests := map[string]GitlabSnippetFile{
+		projectUrl + "/snippets/6/raw":                         {Path: "setup.sh"},
+		projectUrl + "/snippets/6/files/master/setup.sh/raw":   {Path: "setup.sh", RawUrl: snippet.WebUrl + "/raw/master/setup.sh"},
+		projectUrl + "/snippets/6/files/main/a%2Fsetup.sh/raw": {Path: "a/setup.sh", RawUrl: "https://gitlab.com/unexpected/raw/url"},
+	}
+	for expected, file := range tests {
+		if fileUrl := getGitlabSnippetFileUrl(projectUrl, snippet, file); fileUrl != expected {
+			t.Errorf("expected url %s for %v, but got %s", expected, file, fileUrl)
+		}
+	}
+}
+
+func TestGitlabGetItems(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var response interface{}
+		switch r.URL.Path {
+		case "/api/v4/projects/group/app":
+			if r.URL.EscapedPath() != "/api/v4/projects/group%2Fapp" {
+				t.Errorf("expected escaped project path, but got %s", r.URL.EscapedPath())
+			}
+			response = map[string]interface{}{
+				"id": 7, "path_with_namespace": "group/app", "web_url": "https://gitlab.com/group/app",
+				"issues_enabled": true, "merge_requests_enabled": true, "snippets_enabled": true, "wiki_enabled": true,
+			}
+		case "/api/v4/projects/group/private":
+			response = map[string]interface{}{"id": 8, "path_with_namespace": "group/private", "issues_enabled": true}
+		case "/api/v4/projects/8/issues":
+			w.WriteHeader(http.StatusForbidden)
+			return
+		case "/api/v4/projects/7/issues":
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				response = []map[string]interface{}{{"iid": 1, "title": "leak", "description": "password=123", "web_url": "https://gitlab.com/group/app/-/issues/1"}}
+			} else {
+				response = []map[string]interface{}{{"iid": 2, "title": "empty", "web_url": "https://gitlab.com/group/app/-/issues/2"}}
+			}
+		case "/api/v4/projects/7/issues/1/notes":
+			response = []map[string]interface{}{
+				{"id": 100, "body": "token=abc"},
+				{"id": 101, "body": "changed the description", "system": true},
+			}
+		case "/api/v4/projects/7/issues/2/notes", "/api/v4/projects/7/merge_requests/3/notes":
+			response = []interface{}{}
+		case "/api/v4/projects/7/merge_requests":
+			response = []map[string]interface{}{{"iid": 3, "title": "add config", "description": "", "web_url": "https://gitlab.com/group/app/-/merge_requests/3"}}
+		case "/api/v4/projects/7/snippets":
+			response = []map[string]interface{}{
+				{"id": 5, "title": "setup", "file_name": "setup.sh", "web_url": "https://gitlab.com/group/app/-/snippets/5"},
+				{"id": 6, "title": "configs", "web_url": "https://gitlab.com/group/app/-/snippets/6", "files": []map[string]string{
+					{"path": "config/prod.env", "raw_url": "https://gitlab.com/group/app/-/snippets/6/raw/release/config/prod.env"},
+				}},
+			}
+		case "/api/v4/projects/7/snippets/5/raw":
+			_, _ = w.Write([]byte("export AWS_KEY=123"))
+			return
+		case "/api/v4/projects/7/snippets/6/files/release/config/prod.env/raw":
+			if r.URL.EscapedPath() != "/api/v4/projects/7/snippets/6/files/release/config%2Fprod.env/raw" {
+				t.Errorf("expected an encoded file path, but got %s", r.URL.EscapedPath())
+			}
+			_, _ = w.Write([]byte("DB_PASSWORD=123"))
+			return
+		case "/api/v4/projects/7/wikis":
+			response = []map[string]string{{"slug": "home", "title": "Home", "content": "ssh key"}}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("error while encoding response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	p := &GitlabPlugin{
+		Channels:      Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}},
+		Projects:      []string{"group/app", "group/private"},
+		Token:         "token",
+		BaseURL:       server.URL + "/",
+		Issues:        true,
+		MergeRequests: true,
+		Snippets:      true,
+		Wikis:         true,
+	}
+	p.initialize()
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	expected := []Item{
+		{Content: "leak\npassword=123", ID: "https://gitlab.com/group/app/-/issues/1", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeIssue}},
+		{Content: "token=abc", ID: "https://gitlab.com/group/app/-/issues/1#note_100", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeIssueNote}},
+		{Content: "empty", ID: "https://gitlab.com/group/app/-/issues/2", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeIssue}},
+		{Content: "add config", ID: "https://gitlab.com/group/app/-/merge_requests/3", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeMergeRequest}},
+		{Content: "setup", ID: "https://gitlab.com/group/app/-/snippets/5", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeSnippet}},
+		{Content: "export AWS_KEY=123", ID: "https://gitlab.com/group/app/-/snippets/5; File: setup.sh", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeSnippet}},
+		{Content: "configs", ID: "https://gitlab.com/group/app/-/snippets/6", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeSnippet}},
+		{Content: "DB_PASSWORD=123", ID: "https://gitlab.com/group/app/-/snippets/6; File: config/prod.env", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeSnippet}},
+		{Content: "Home\nssh key", ID: "https://gitlab.com/group/app/-/wikis/home", Metadata: map[string]string{gitlabContentTypeKey: gitlabTypeWiki}},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID || item.Metadata[gitlabContentTypeKey] != expected[i].Metadata[gitlabContentTypeKey] {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+		if item.Metadata[gitlabProjectKey] != "group/app" {
+			t.Errorf("expected project group/app, but got %v", item.Metadata)
+		}
+	}
+}
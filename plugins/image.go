@@ -0,0 +1,805 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	imagePathFlag      = "path"
+	imageReferenceFlag = "image"
+	imagePlatformFlag  = "platform"
+	imageUsernameFlag  = "username"
+	imagePasswordFlag  = "password"
+	imagePlainHttpFlag = "plain-http"
+)
+
+const (
+	imageDefaultPlatform = "linux/amd64"
+	imageMaxLayers       = 3
+	imageLayerKey        = "layer"
+	imageContentTypeKey  = "contentType"
+	imageTypeFile        = "file"
+	imageTypeEnv         = "env"
+	imageTypeHistory     = "history"
+)
+
+const (
+	mediaTypeOciIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOciManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+const dockerHubRegistry = "registry-1.docker.io"
+
+var registryChallengeRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+type ImagePlugin struct {
+	Plugin
+	Channels
+
+	Path      string
+	Reference string
+	Platform  string
+	Username  string
+	Password  string
+	PlainHttp bool
+}
+
+func (p *ImagePlugin) GetName() string {
+	return "image"
+}
+
+func (p *ImagePlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s PATH | --%s IMAGE", p.GetName(), imagePathFlag, imageReferenceFlag),
+		Short: "Scan container image",
+		Long:  "Scan the files of the layers, the environment variables and the history of a container image for sensitive information",
+		Run: func(cmd *cobra.Command, args []string) {
+			if p.Path == "" && p.Reference == "" {
+				p.Errors <- fmt.Errorf("at least one of the flags in the group [%s %s] is required", imagePathFlag, imageReferenceFlag)
+				return
+			}
+			log.Info().Msg("Image plugin started")
+			p.Limit = make(chan struct{}, imageMaxLayers)
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Path, imagePathFlag, "", "Path of an OCI image layout directory or of an image tarball (docker save), which may be gzip compressed")
+	flags.StringVar(&p.Reference, imageReferenceFlag, "", "Image reference to pull from a registry (example: ghcr.io/company/app:1.0)")
+	flags.StringVar(&p.Platform, imagePlatformFlag, imageDefaultPlatform, "Platform of the image to scan in a multi-platform image (os/arch[/variant])")
+	flags.StringVar(&p.Username, imageUsernameFlag, "", "Registry user name")
+	flags.StringVar(&p.Password, imagePasswordFlag, "", "Registry password or token")
+	flags.BoolVar(&p.PlainHttp, imagePlainHttpFlag, false, "Connect to the registry with HTTP instead of HTTPS")
+	command.MarkFlagsMutuallyExclusive(imagePathFlag, imageReferenceFlag)
+
+	return command, nil
+}
+
+// containerImage is an image loaded from an archive or a registry, its layers are read on demand
+type containerImage struct {
+	name   string
+	digest string
+	config []byte
+	layers []containerLayer
+}
+
+type containerLayer struct {
+	digest string
+	open   func() (io.ReadCloser, error)
+}
+
+func (p *ImagePlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		images, closeImages, err := p.loadImages()
+		if err != nil {
+			p.Errors <- fmt.Errorf("error while loading image: %w", err)
+			return
+		}
+		// the archive is closed once all its layers are read
+		defer closeImages()
+		layersWaitGroup := &sync.WaitGroup{}
+		defer layersWaitGroup.Wait()
+
+		for _, image := range images {
+			log.Info().Msgf("Scanning image %s@%s with %d layers", image.name, image.digest, len(image.layers))
+			if err := p.sendConfigItems(image); err != nil {
+				p.Errors <- err
+				return
+			}
+
+			for _, layer := range image.layers {
+				layersWaitGroup.Add(1)
+				p.Limit <- struct{}{}
+				go func(image containerImage, layer containerLayer) {
+					defer layersWaitGroup.Done()
+					if err := p.getLayerItems(image, layer); err != nil {
+						p.Errors <- err
+					}
+					<-p.Limit
+				}(image, layer)
+			}
+		}
+	}()
+}
+
+// loadImages returns the images and a function releasing the archive they are read from
+func (p *ImagePlugin) loadImages() ([]containerImage, func(), error) {
+	if p.Reference != "" {
+		image, err := p.loadRegistryImage()
+		if err != nil {
+			return nil, nil, err
+		}
+		return []containerImage{*image}, func() {}, nil
+	}
+
+	info, err := os.Stat(p.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var archive imageArchive = &dirImageArchive{root: p.Path}
+	if !info.IsDir() {
+		if archive, err = openTarImageArchive(p.Path); err != nil {
+			return nil, nil, err
+		}
+	}
+	closeArchive := func() {
+		if err := archive.close(); err != nil {
+			log.Warn().Msgf("Failed to close image archive %s: %s", p.Path, err)
+		}
+	}
+
+	images, err := p.loadArchiveImages(archive)
+	if err != nil {
+		closeArchive()
+		return nil, nil, err
+	}
+	return images, closeArchive, nil
+}
+
+func getImageItemId(image containerImage, location string) string {
+	return fmt.Sprintf("%s@%s:%s", image.name, image.digest, location)
+}
+
+func (p *ImagePlugin) sendConfigItems(image containerImage) error {
+	config := ImageConfig{}
+	if err := json.Unmarshal(image.config, &config); err != nil {
+		return fmt.Errorf("could not unmarshal image config %w", err)
+	}
+
+	if len(config.Config.Env) > 0 {
+		p.Items <- Item{
+			Content:  strings.Join(config.Config.Env, "\n"),
+			ID:       getImageItemId(image, "config/Env"),
+			Metadata: map[string]string{imageContentTypeKey: imageTypeEnv},
+		}
+	}
+
+	commands := []string{}
+	for _, history := range config.History {
+		commands = append(commands, history.CreatedBy)
+	}
+	if len(commands) > 0 {
+		p.Items <- Item{
+			Content:  strings.Join(commands, "\n"),
+			ID:       getImageItemId(image, "config/history"),
+			Metadata: map[string]string{imageContentTypeKey: imageTypeHistory},
+		}
+	}
+	return nil
+}
+
+// getLayerItems sends the text files of a layer, skipping the same folders and empty files as the filesystem plugin
+func (p *ImagePlugin) getLayerItems(image containerImage, layer containerLayer) error {
+	blob, err := layer.open()
+	if err != nil {
+		return fmt.Errorf("error while opening layer %s: %w", layer.digest, err)
+	}
+	defer blob.Close()
+
+	reader, err := uncompressLayer(blob)
+	if err != nil {
+		log.Warn().Msgf("Skipping layer %s: %s", layer.digest, err)
+		return nil
+	}
+
+	layerTar := tar.NewReader(reader)
+	for {
+		header, err := layerTar.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error while reading layer %s: %w", layer.digest, err)
+		}
+
+		filePath := strings.TrimPrefix(path.Clean("/"+header.Name), "/")
+		if header.Typeflag != tar.TypeReg || header.Size == 0 || isIgnoredLayerFile(filePath) {
+			continue
+		}
+		if header.Size > maxAttachmentSize {
+			log.Debug().Msgf("Skipping file %s of layer %s: size %d exceeds the limit of %d bytes", filePath, layer.digest, header.Size, maxAttachmentSize)
+			continue
+		}
+
+		data, err := io.ReadAll(layerTar)
+		if err != nil {
+			return fmt.Errorf("error while reading file %s of layer %s: %w", filePath, layer.digest, err)
+		}
+		content, err := getAttachmentContent(filePath, "", data)
+		if err != nil {
+			continue
+		}
+
+		p.Items <- Item{
+			Content:  content,
+			ID:       getImageItemId(image, fmt.Sprintf("%s/%s", layer.digest, filePath)),
+			Metadata: map[string]string{imageContentTypeKey: imageTypeFile, imageLayerKey: layer.digest},
+		}
+	}
+}
+
+// isIgnoredLayerFile returns true for the whiteout files marking the deleted files, and the files of the ignored folders
+func isIgnoredLayerFile(filePath string) bool {
+	if strings.HasPrefix(path.Base(filePath), ".wh.") {
+		return true
+	}
+	for _, folder := range strings.Split(path.Dir(filePath), "/") {
+		if isIgnoredFolder(folder) {
+			return true
+		}
+	}
+	return false
+}
+
+// uncompressLayer returns the tar stream of a layer, which may be gzip compressed or not
+func uncompressLayer(blob io.Reader) (io.Reader, error) {
+	reader := bufio.NewReader(blob)
+	magic, err := reader.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		return gzip.NewReader(reader)
+	}
+	if len(magic) >= 4 && magic[0] == 0x28 && magic[1] == 0xb5 && magic[2] == 0x2f && magic[3] == 0xfd {
+		return nil, fmt.Errorf("zstd compressed layers are not supported")
+	}
+	return reader, nil
+}
+
+// imageArchive is an OCI image layout or a docker save tarball, containing an index.json or a manifest.json file
+type imageArchive interface {
+	name() string
+	open(name string) (io.ReadCloser, error)
+	close() error
+}
+
+type dirImageArchive struct {
+	root string
+}
+
+func (a *dirImageArchive) name() string {
+	return filepath.Base(filepath.Clean(a.root))
+}
+
+func (a *dirImageArchive) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(a.root, filepath.FromSlash(name)))
+}
+
+func (a *dirImageArchive) close() error {
+	return nil
+}
+
+// tarImageArchive indexes the files of the tarball once, then reads each file at its offset, the layers are too big
+// to be kept in memory. A gzip compressed tarball (docker save | gzip) is uncompressed to a temporary file first.
+type tarImageArchive struct {
+	path     string
+	file     *os.File
+	tempFile string
+	entries  map[string]tarEntry
+}
+
+type tarEntry struct {
+	offset int64
+	size   int64
+}
+
+func openTarImageArchive(archivePath string) (*tarImageArchive, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	archive := &tarImageArchive{path: archivePath, file: file, entries: map[string]tarEntry{}}
+
+	magic := make([]byte, 2)
+	if _, err := file.ReadAt(magic, 0); err != nil && err != io.EOF {
+		archive.close()
+		return nil, err
+	}
+	if magic[0] == 0x1f && magic[1] == 0x8b {
+		if err := archive.uncompress(); err != nil {
+			archive.close()
+			return nil, fmt.Errorf("error while uncompressing %s: %w", archivePath, err)
+		}
+	}
+
+	if err := archive.index(); err != nil {
+		archive.close()
+		return nil, fmt.Errorf("error while reading %s: %w", archivePath, err)
+	}
+	return archive, nil
+}
+
+func (a *tarImageArchive) uncompress() error {
+	reader, err := gzip.NewReader(a.file)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp("", "2ms-image-*.tar")
+	if err != nil {
+		return err
+	}
+	compressedFile := a.file
+	defer compressedFile.Close()
+	a.file = tempFile
+	a.tempFile = tempFile.Name()
+
+	_, err = io.Copy(tempFile, reader)
+	return err
+}
+
+// index reads the headers of the tarball, the tar reader seeks over the content of the files
+func (a *tarImageArchive) index() error {
+	if _, err := a.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	reader := tar.NewReader(a.file)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		offset, err := a.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		a.entries[path.Clean(header.Name)] = tarEntry{offset: offset, size: header.Size}
+	}
+}
+
+func (a *tarImageArchive) name() string {
+	name := strings.TrimSuffix(filepath.Base(a.path), ".gz")
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func (a *tarImageArchive) open(name string) (io.ReadCloser, error) {
+	entry, ok := a.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+	}
+	return io.NopCloser(io.NewSectionReader(a.file, entry.offset, entry.size)), nil
+}
+
+func (a *tarImageArchive) close() error {
+	err := a.file.Close()
+	if a.tempFile != "" {
+		os.Remove(a.tempFile)
+	}
+	return err
+}
+
+func readArchiveFile(archive imageArchive, name string) ([]byte, error) {
+	reader, err := archive.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (p *ImagePlugin) loadArchiveImages(archive imageArchive) ([]containerImage, error) {
+	manifestData, err := readArchiveFile(archive, "manifest.json")
+	if err == nil {
+		return loadDockerArchiveImages(archive, manifestData)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	indexData, err := readArchiveFile(archive, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("neither manifest.json nor index.json found, the path is not an OCI image layout or a docker save tarball: %w", err)
+	}
+	return p.loadOciArchiveImages(archive, indexData)
+}
+
+// loadDockerArchiveImages loads the images of a docker save tarball, the image digest is its ID (the config digest)
+func loadDockerArchiveImages(archive imageArchive, manifestData []byte) ([]containerImage, error) {
+	manifests := []DockerArchiveManifest{}
+	if err := json.Unmarshal(manifestData, &manifests); err != nil {
+		return nil, fmt.Errorf("could not unmarshal manifest.json %w", err)
+	}
+
+	images := []containerImage{}
+	for _, manifest := range manifests {
+		config, err := readArchiveFile(archive, path.Clean(manifest.Config))
+		if err != nil {
+			return nil, fmt.Errorf("error while reading image config %s: %w", manifest.Config, err)
+		}
+
+		image := containerImage{name: archive.name(), digest: getArchiveFileDigest(manifest.Config), config: config}
+		if len(manifest.RepoTags) > 0 {
+			image.name = manifest.RepoTags[0]
+		}
+		for _, layerPath := range manifest.Layers {
+			layerPath := path.Clean(layerPath)
+			image.layers = append(image.layers, containerLayer{
+				digest: getArchiveFileDigest(layerPath),
+				open:   func() (io.ReadCloser, error) { return archive.open(layerPath) },
+			})
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// getArchiveFileDigest returns the digest of a blob from its path, blobs/sha256/<hex> or <hex>.json and <hex>/layer.tar in older tarballs
+func getArchiveFileDigest(filePath string) string {
+	if strings.HasPrefix(filePath, "blobs/") {
+		algorithm, hex, _ := strings.Cut(strings.TrimPrefix(filePath, "blobs/"), "/")
+		return algorithm + ":" + hex
+	}
+	if dir := path.Dir(filePath); dir != "." {
+		return "sha256:" + dir
+	}
+	return "sha256:" + strings.TrimSuffix(filePath, path.Ext(filePath))
+}
+
+func getBlobPath(digest string) string {
+	return "blobs/" + strings.Replace(digest, ":", "/", 1)
+}
+
+func (p *ImagePlugin) loadOciArchiveImages(archive imageArchive, indexData []byte) ([]containerImage, error) {
+	fetch := func(descriptor OciDescriptor) ([]byte, error) {
+		return readArchiveFile(archive, getBlobPath(descriptor.Digest))
+	}
+
+	index := OciManifest{}
+	if err := json.Unmarshal(indexData, &index); err != nil {
+		return nil, fmt.Errorf("could not unmarshal index.json %w", err)
+	}
+
+	images := []containerImage{}
+	for _, descriptor := range index.Manifests {
+		manifest, digest, err := p.resolveManifest(descriptor, fetch)
+		if err != nil {
+			return nil, err
+		}
+		config, err := fetch(manifest.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading image config %s: %w", manifest.Config.Digest, err)
+		}
+
+		image := containerImage{name: archive.name(), digest: digest, config: config}
+		if name, ok := descriptor.Annotations["io.containerd.image.name"]; ok {
+			image.name = name
+		} else if name, ok := descriptor.Annotations["org.opencontainers.image.ref.name"]; ok {
+			image.name = name
+		}
+		for _, layer := range manifest.Layers {
+			blobPath := getBlobPath(layer.Digest)
+			image.layers = append(image.layers, containerLayer{
+				digest: layer.Digest,
+				open:   func() (io.ReadCloser, error) { return archive.open(blobPath) },
+			})
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+// resolveManifest returns the image manifest of a descriptor, selecting the manifest of the platform in the image indexes
+func (p *ImagePlugin) resolveManifest(descriptor OciDescriptor, fetch func(descriptor OciDescriptor) ([]byte, error)) (*OciManifest, string, error) {
+	data, err := fetch(descriptor)
+	if err != nil {
+		return nil, "", fmt.Errorf("error while reading manifest %s: %w", descriptor.Digest, err)
+	}
+	manifest := OciManifest{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, "", fmt.Errorf("could not unmarshal manifest %w", err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = descriptor.MediaType
+	}
+
+	if manifest.MediaType != mediaTypeOciIndex && manifest.MediaType != mediaTypeDockerManifestList {
+		return &manifest, descriptor.Digest, nil
+	}
+
+	for _, platformManifest := range manifest.Manifests {
+		if platformManifest.Platform != nil && platformManifest.Platform.matches(p.Platform) {
+			return p.resolveManifest(platformManifest, fetch)
+		}
+	}
+	return nil, "", fmt.Errorf("no image found for platform %s in %s", p.Platform, descriptor.Digest)
+}
+
+func (p *ImagePlugin) loadRegistryImage() (*containerImage, error) {
+	registry, repository, reference := parseImageReference(p.Reference)
+	scheme := "https"
+	if p.PlainHttp {
+		scheme = "http"
+	}
+	client := &registryClient{
+		url:      fmt.Sprintf("%s://%s/v2/%s", scheme, registry, repository),
+		username: p.Username,
+		password: p.Password,
+	}
+
+	manifests := map[string][]byte{}
+	fetch := func(descriptor OciDescriptor) ([]byte, error) {
+		if data, ok := manifests[descriptor.Digest]; ok {
+			return data, nil
+		}
+		return client.get("manifests/" + descriptor.Digest)
+	}
+	if !strings.Contains(reference, ":") {
+		// the reference is a tag, the image is identified by the digest of its manifest
+		data, err := fetch(OciDescriptor{Digest: reference})
+		if err != nil {
+			return nil, fmt.Errorf("error while reading manifest %s: %w", reference, err)
+		}
+		reference = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+		manifests[reference] = data
+	}
+	manifest, digest, err := p.resolveManifest(OciDescriptor{Digest: reference}, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := client.get("blobs/" + manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("error while downloading image config %s: %w", manifest.Config.Digest, err)
+	}
+
+	image := &containerImage{name: strings.Join([]string{registry, repository}, "/"), digest: digest, config: config}
+	for _, layer := range manifest.Layers {
+		layerDigest := layer.Digest
+		image.layers = append(image.layers, containerLayer{
+			digest: layerDigest,
+			open:   func() (io.ReadCloser, error) { return client.open("blobs/" + layerDigest) },
+		})
+	}
+	return image, nil
+}
+
+// parseImageReference splits a reference like ghcr.io/company/app:1.0 in registry, repository and tag or digest
+func parseImageReference(reference string) (string, string, string) {
+	registry := dockerHubRegistry
+	repository := reference
+	if first, rest, found := strings.Cut(reference, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		registry = first
+		repository = rest
+	}
+
+	tag := "latest"
+	if name, digest, found := strings.Cut(repository, "@"); found {
+		repository, tag = name, digest
+	} else if i := strings.LastIndex(repository, ":"); i != -1 {
+		repository, tag = repository[:i], repository[i+1:]
+	}
+
+	if registry == dockerHubRegistry && !strings.Contains(repository, "/") {
+		repository = "library/" + repository
+	}
+	return registry, repository, tag
+}
+
+// registryClient requests a registry API, getting a token from the authorization server when the registry asks for it.
+// The layers are downloaded concurrently, the token is shared and renewed once when it expires.
+type registryClient struct {
+	url      string
+	username string
+	password string
+
+	mu    sync.Mutex
+	token string
+}
+
+func (c *registryClient) get(resource string) ([]byte, error) {
+	reader, err := c.open(resource)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func (c *registryClient) open(resource string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	token := c.token
+	c.mu.Unlock()
+
+	response, err := c.request(resource, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+		if token, err = c.renewToken(token, challenge); err != nil {
+			return nil, err
+		}
+		if response, err = c.request(resource, token); err != nil {
+			return nil, err
+		}
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		response.Body.Close()
+		return nil, fmt.Errorf("error calling registry url \"%s/%s\". status code: %d", c.url, resource, response.StatusCode)
+	}
+	return response.Body, nil
+}
+
+// renewToken authenticates again, unless another request already replaced the rejected token
+func (c *registryClient) renewToken(rejectedToken string, challenge string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != rejectedToken {
+		return c.token, nil
+	}
+
+	token, err := c.authenticate(challenge)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	return token, nil
+}
+
+func (c *registryClient) request(resource string, token string) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", c.url, resource), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error creating an http request %w", err)
+	}
+	request.Header.Set("Accept", strings.Join([]string{mediaTypeOciIndex, mediaTypeOciManifest, mediaTypeDockerManifestList, mediaTypeDockerManifest}, ", "))
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	} else if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send http request %w", err)
+	}
+	return response, nil
+}
+
+// authenticate gets a token from the realm of a Bearer challenge https://distribution.github.io/distribution/spec/auth/token/
+func (c *registryClient) authenticate(challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", fmt.Errorf("registry authentication failed, check the %s and %s flags", imageUsernameFlag, imagePasswordFlag)
+	}
+
+	params := map[string]string{}
+	for _, match := range registryChallengeRegex.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	request, err := http.NewRequest(http.MethodGet, params["realm"], nil)
+	if err != nil {
+		return "", fmt.Errorf("unexpected error creating an http request %w", err)
+	}
+	query := request.URL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	request.URL.RawQuery = query.Encode()
+	if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("unable to send http request %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error getting registry token. status code: %d", response.StatusCode)
+	}
+
+	token := RegistryToken{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("could not unmarshal response %w", err)
+	}
+	if token.Token == "" {
+		return token.AccessToken, nil
+	}
+	return token.Token, nil
+}
+
+type DockerArchiveManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// OciManifest is an image manifest or an image index
+type OciManifest struct {
+	MediaType string          `json:"mediaType"`
+	Config    OciDescriptor   `json:"config"`
+	Layers    []OciDescriptor `json:"layers"`
+	Manifests []OciDescriptor `json:"manifests"`
+}
+
+type OciDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+	Platform    *OciPlatform      `json:"platform"`
+}
+
+type OciPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant"`
+}
+
+// matches compares the platform with an os/arch[/variant] string, the variant is ignored when not given
+func (p *OciPlatform) matches(platform string) bool {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || parts[0] != p.OS || parts[1] != p.Architecture {
+		return false
+	}
+	return len(parts) < 3 || parts[2] == p.Variant
+}
+
+type ImageConfig struct {
+	Config struct {
+		Env []string `json:"Env"`
+	} `json:"config"`
+	History []struct {
+		CreatedBy string `json:"created_by"`
+	} `json:"history"`
+}
+
+type RegistryToken struct {
+	Token       string `json:"token"`
+	AccessToken string `json:"access_token"`
+}
@@ -0,0 +1,290 @@
+package plugins
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type tarFile struct {
+	name    string
+	content []byte
+}
+
+func createTar(t *testing.T, files []tarFile) []byte {
+	buffer := &bytes.Buffer{}
+	writer := tar.NewWriter(buffer)
+	for _, file := range files {
+		if err := writer.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("error while writing tar header: %v", err)
+		}
+		if _, err := writer.Write(file.content); err != nil {
+			t.Fatalf("error while writing tar file: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("error while closing tar: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatalf("error while writing gzip: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("error while closing gzip: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func getDigest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+func marshal(t *testing.T, value interface{}) []byte {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("error while marshaling: %v", err)
+	}
+	return data
+}
+
+// testImage is a single layer image, with the blobs of an OCI image layout
+type testImage struct {
+	layer    []byte
+	config   []byte
+	manifest []byte
+	blobs    map[string][]byte
+}
+
+func newTestImage(t *testing.T) *testImage {
+	image := &testImage{blobs: map[string][]byte{}}
+	image.layer = gzipData(t, createTar(t, []tarFile{
+		{name: "app/.env", content: []byte("AWS_SECRET_ACCESS_KEY=123")},
+		{name: "app/.git/config", content: []byte("token=ignored")},
+		{name: "app/.wh.old.env", content: []byte("deleted")},
+		{name: "usr/bin/tool", content: []byte{0x7f, 'E', 'L', 'F', 0, 0, 0}},
+	}))
+	image.config = marshal(t, map[string]interface{}{
+		"config":  map[string]interface{}{"Env": []string{"PATH=/usr/bin", "API_TOKEN=abc"}},
+		"history": []map[string]string{{"created_by": "ENV API_TOKEN=abc"}, {"created_by": "COPY . /app"}},
+	})
+	image.manifest = marshal(t, map[string]interface{}{
+		"mediaType": mediaTypeOciManifest,
+		"config":    map[string]interface{}{"digest": getDigest(image.config), "size": len(image.config)},
+		"layers":    []map[string]interface{}{{"digest": getDigest(image.layer), "size": len(image.layer)}},
+	})
+	for _, blob := range [][]byte{image.layer, image.config, image.manifest} {
+		image.blobs[getDigest(blob)] = blob
+	}
+	return image
+}
+
+func (image *testImage) expectedItems(name string, digest string) []Item {
+	layer := getDigest(image.layer)
+	items := []Item{
+		{Content: "PATH=/usr/bin\nAPI_TOKEN=abc", ID: fmt.Sprintf("%s@%s:config/Env", name, digest)},
+		{Content: "ENV API_TOKEN=abc\nCOPY . /app", ID: fmt.Sprintf("%s@%s:config/history", name, digest)},
+		{Content: "AWS_SECRET_ACCESS_KEY=123", ID: fmt.Sprintf("%s@%s:%s/app/.env", name, digest, layer)},
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func scanImage(t *testing.T, p *ImagePlugin) []Item {
+	p.Channels = Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}}
+	p.Limit = make(chan struct{}, imageMaxLayers)
+	if p.Platform == "" {
+		p.Platform = imageDefaultPlatform
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	// layers are scanned concurrently
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func assertImageItems(t *testing.T, items []Item, expected []Item) {
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+	}
+}
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		reference  string
+		registry   string
+		repository string
+		tag        string
+	}{
+		{"alpine", dockerHubRegistry, "library/alpine", "latest"},
+		{"company/app:1.0", dockerHubRegistry, "company/app", "1.0"},
+		{"ghcr.io/company/app:1.0", "ghcr.io", "company/app", "1.0"},
+		{"localhost:5000/app@sha256:abc", "localhost:5000", "app", "sha256:abc"},
+	}
+	for _, test := range tests {
+		registry, repository, tag := parseImageReference(test.reference)
+		if registry != test.registry || repository != test.repository || tag != test.tag {
+			t.Errorf("unexpected parsing of %s: %s %s %s", test.reference, registry, repository, tag)
+		}
+	}
+}
+
+func TestImageOciLayout(t *testing.T) {
+	image := newTestImage(t)
+	manifestDigest := getDigest(image.manifest)
+	imageIndex := marshal(t, map[string]interface{}{
+		"mediaType": mediaTypeOciIndex,
+		"manifests": []map[string]interface{}{
+			{"mediaType": mediaTypeOciManifest, "digest": "sha256:missing", "platform": map[string]string{"os": "linux", "architecture": "arm64"}},
+			{"mediaType": mediaTypeOciManifest, "digest": manifestDigest, "platform": map[string]string{"os": "linux", "architecture": "amd64"}},
+		},
+	})
+	image.blobs[getDigest(imageIndex)] = imageIndex
+
+	dir := t.TempDir()
+	for digest, blob := range image.blobs {
+		blobPath := filepath.Join(dir, filepath.FromSlash(getBlobPath(digest)))
+		if err := os.MkdirAll(filepath.Dir(blobPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(blobPath, blob, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	index := marshal(t, map[string]interface{}{
+		"manifests": []map[string]interface{}{{
+			"mediaType":   mediaTypeOciIndex,
+			"digest":      getDigest(imageIndex),
+			"annotations": map[string]string{"org.opencontainers.image.ref.name": "app:1.0"},
+		}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "index.json"), index, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	items := scanImage(t, &ImagePlugin{Path: dir})
+
+	assertImageItems(t, items, image.expectedItems("app:1.0", manifestDigest))
+}
+
+func TestImageDockerArchive(t *testing.T) {
+	image := newTestImage(t)
+	configHex := strings.TrimPrefix(getDigest(image.config), "sha256:")
+	layerHex := strings.TrimPrefix(getDigest(image.layer), "sha256:")
+	manifest := marshal(t, []map[string]interface{}{{
+		"Config":   configHex + ".json",
+		"RepoTags": []string{"app:latest"},
+		"Layers":   []string{layerHex + "/layer.tar"},
+	}})
+	archive := createTar(t, []tarFile{
+		{name: layerHex + "/layer.tar", content: image.layer},
+		{name: configHex + ".json", content: image.config},
+		{name: "manifest.json", content: manifest},
+	})
+
+	archives := map[string][]byte{
+		"app.tar": archive,
+		// docker save app:latest | gzip
+		"app.tar.gz": gzipData(t, archive),
+	}
+	for name, content := range archives {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(archivePath, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			items := scanImage(t, &ImagePlugin{Path: archivePath})
+
+			assertImageItems(t, items, image.expectedItems("app:latest", getDigest(image.config)))
+		})
+	}
+}
+
+func TestImageRegistry(t *testing.T) {
+	image := newTestImage(t)
+	var server *httptest.Server
+	mu := sync.Mutex{}
+	tokens := 0
+	validToken := ""
+	manifestRequests := 0
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:company/app:pull" {
+				t.Errorf("unexpected token scope %s", r.URL.Query().Get("scope"))
+			}
+			tokens++
+			validToken = fmt.Sprintf("registry-token-%d", tokens)
+			_, _ = w.Write([]byte(fmt.Sprintf(`{"token": "%s"}`, validToken)))
+			return
+		}
+		if validToken == "" || r.Header.Get("Authorization") != "Bearer "+validToken {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:company/app:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		resource := strings.TrimPrefix(r.URL.Path, "/v2/company/app/")
+		if resource == "manifests/1.0" {
+			manifestRequests++
+			// the token expires before the blobs are downloaded
+			validToken = ""
+			_, _ = w.Write(image.manifest)
+			return
+		}
+		_, digest, _ := strings.Cut(resource, "/")
+		blob, ok := image.blobs[digest]
+		if !ok {
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(blob)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	items := scanImage(t, &ImagePlugin{Reference: host + "/company/app:1.0", PlainHttp: true})
+
+	assertImageItems(t, items, image.expectedItems(host+"/company/app", getDigest(image.manifest)))
+	if manifestRequests != 1 || tokens != 2 {
+		t.Errorf("expected 1 manifest request and 2 tokens, but got %d manifest requests and %d tokens", manifestRequests, tokens)
+	}
+}
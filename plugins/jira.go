@@ -0,0 +1,452 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	jiraUrlFlag          = "url"
+	jiraProjectsFlag     = "projects"
+	jiraJqlFlag          = "jql"
+	jiraUsernameFlag     = "username"
+	jiraTokenFlag        = "token"
+	jiraAuthTypeFlag     = "auth-type"
+	jiraCommentsFlag     = "comments"
+	jiraAttachmentsFlag  = "attachments"
+	jiraLimitFlag        = "limit"
+	jiraDefaultLimit     = 50
+	jiraMaxIssueRequests = 10
+)
+
+const (
+	jiraTypeIssue      = "issue"
+	jiraTypeComment    = "comment"
+	jiraTypeAttachment = "attachment"
+	jiraContentTypeKey = "contentType"
+	jiraProjectKey     = "project"
+)
+
+// Fields of the issues requested by the search
+const jiraSearchFields = "summary,description,comment,attachment,project"
+
+type JiraPlugin struct {
+	Plugin
+	Channels
+
+	URL         string
+	Username    string
+	Token       string
+	AuthType    string
+	Projects    []string
+	JQL         string
+	Comments    bool
+	Attachments bool
+	// Number of results requested per page, the server may return less
+	ResultsLimit int
+
+	cloud bool
+}
+
+func (p *JiraPlugin) GetName() string {
+	return "jira"
+}
+
+func (p *JiraPlugin) GetCredentials() (string, string) {
+	return p.Username, p.Token
+}
+
+func (p *JiraPlugin) GetAuthorizationHeader() (string, error) {
+	switch p.AuthType {
+	case lib.AuthTypeBearer:
+		return lib.CreateBearerAuthCredentials(p.Token), nil
+	default:
+		if p.Username == "" && p.Token == "" {
+			return "", nil
+		}
+		return lib.CreateBasicAuthCredentials(p), nil
+	}
+}
+
+func (p *JiraPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s URL", p.GetName(), jiraUrlFlag),
+		Short: "Scan Jira server",
+		Long:  "Scan Jira Cloud or Jira Server issues for sensitive information",
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(p.Projects) == 0 && p.JQL == "" {
+				p.Errors <- fmt.Errorf("at least one of the flags in the group [%s %s] is required", jiraProjectsFlag, jiraJqlFlag)
+				return
+			}
+			if err := p.initialize(); err != nil {
+				p.Errors <- fmt.Errorf("error while initializing jira plugin: %w", err)
+				return
+			}
+			log.Info().Msg("Jira plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.URL, jiraUrlFlag, "", "Jira server URL (example: https://company.atlassian.net) [required]")
+	flags.StringSliceVar(&p.Projects, jiraProjectsFlag, []string{}, "Jira projects: The keys of the projects to scan")
+	flags.StringVar(&p.JQL, jiraJqlFlag, "", "Scan the issues matching a JQL query instead of whole projects (example: 'project = OPS and updated >= -30d')")
+	flags.StringVar(&p.Username, jiraUsernameFlag, "", "Jira user name or email for authentication")
+	flags.StringVar(&p.Token, jiraTokenFlag, "", "The Jira API token (basic) or personal access token (bearer) for authentication")
+	flags.StringVar(&p.AuthType, jiraAuthTypeFlag, lib.AuthTypeBasic, fmt.Sprintf("Jira authentication type (%s, %s)", lib.AuthTypeBasic, lib.AuthTypeBearer))
+	flags.BoolVar(&p.Comments, jiraCommentsFlag, true, "Scan issues comments")
+	flags.BoolVar(&p.Attachments, jiraAttachmentsFlag, true, "Scan issues attachments (text, PDF and Office documents)")
+	flags.IntVar(&p.ResultsLimit, jiraLimitFlag, jiraDefaultLimit, "Number of results requested per API call, the server may apply a lower maximum")
+	command.MarkFlagsMutuallyExclusive(jiraProjectsFlag, jiraJqlFlag)
+	err := command.MarkFlagRequired(jiraUrlFlag)
+	if err != nil {
+		return nil, fmt.Errorf("error while marking '%s' flag as required: %w", jiraUrlFlag, err)
+	}
+
+	return command, nil
+}
+
+func (p *JiraPlugin) initialize() error {
+	p.URL = strings.TrimRight(p.URL, "/")
+	p.AuthType = strings.ToLower(p.AuthType)
+	if p.ResultsLimit <= 0 {
+		return fmt.Errorf("'%s' flag must be a positive number", jiraLimitFlag)
+	}
+
+	switch p.AuthType {
+	case lib.AuthTypeBasic:
+		if p.Username == "" || p.Token == "" {
+			log.Warn().Msg("jira credentials were not provided. The scan will be made anonymously only for the public issues")
+		}
+	case lib.AuthTypeBearer:
+		if p.Token == "" {
+			return fmt.Errorf("'%s' flag is required for the %s authentication type", jiraTokenFlag, lib.AuthTypeBearer)
+		}
+	default:
+		return fmt.Errorf("invalid authentication type: %s, available types are: %s and %s", p.AuthType, lib.AuthTypeBasic, lib.AuthTypeBearer)
+	}
+
+	if p.JQL == "" {
+		p.JQL = getProjectsJql(p.Projects)
+	}
+	p.cloud = p.isCloud()
+	p.Limit = make(chan struct{}, jiraMaxIssueRequests)
+	return nil
+}
+
+// isCloud tells whether the server is Jira Cloud, where the issues are searched with the enhanced search API
+func (p *JiraPlugin) isCloud() bool {
+	body, _, err := p.request(p.URL + "/rest/api/2/serverInfo")
+	if err != nil {
+		log.Warn().Msgf("Unable to get the jira deployment type, searching issues with the Jira Server API: %s", err)
+		return false
+	}
+
+	info := JiraServerInfo{}
+	if err := json.Unmarshal(body, &info); err != nil {
+		log.Warn().Msgf("Unable to get the jira deployment type, searching issues with the Jira Server API: %s", err)
+		return false
+	}
+	return info.DeploymentType == "Cloud"
+}
+
+func (p *JiraPlugin) request(url string) ([]byte, *http.Response, error) {
+	return lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		return lib.HttpRequest(http.MethodGet, url, p)
+	}, lib.RetryAfterDelay)
+}
+
+func getProjectsJql(projects []string) string {
+	quoted := []string{}
+	for _, project := range projects {
+		quoted = append(quoted, fmt.Sprintf("%q", project))
+	}
+	return fmt.Sprintf("project in (%s) ORDER BY key", strings.Join(quoted, ", "))
+}
+
+func (p *JiraPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		total := 0
+		err := p.searchIssues(func(issues []JiraIssue) {
+			total += len(issues)
+			for _, issue := range issues {
+				p.WaitGroup.Add(1)
+				p.Limit <- struct{}{}
+				go func(issue JiraIssue) {
+					defer p.WaitGroup.Done()
+					p.getIssueItems(issue)
+					<-p.Limit
+				}(issue)
+			}
+		})
+		if err != nil {
+			p.Errors <- err
+			return
+		}
+		log.Info().Msgf(" Total of %d issues matched the jql query", total)
+	}()
+}
+
+// searchIssues requests the issues matching the jql query page by page, calling handle with the issues of each page
+func (p *JiraPlugin) searchIssues(handle func(issues []JiraIssue)) error {
+	if p.cloud {
+		return p.searchCloudIssues(handle)
+	}
+
+	for startAt := 0; ; {
+		searchUrl := fmt.Sprintf("%s/rest/api/2/search?jql=%s&fields=%s&startAt=%d&maxResults=%d",
+			p.URL, url.QueryEscape(p.JQL), jiraSearchFields, startAt, p.ResultsLimit)
+		body, _, err := p.request(searchUrl)
+		if err != nil {
+			return fmt.Errorf("unexpected error searching issues with jql: %w", err)
+		}
+
+		response := JiraSearchResponse{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+		handle(response.Issues)
+
+		startAt += len(response.Issues)
+		if len(response.Issues) == 0 || startAt >= response.Total {
+			return nil
+		}
+	}
+}
+
+// searchCloudIssues pages through the enhanced search of Jira Cloud, which replaced startAt by nextPageToken
+// https://developer.atlassian.com/cloud/jira/platform/rest/v3/api-group-issue-search/#api-rest-api-3-search-jql-get
+func (p *JiraPlugin) searchCloudIssues(handle func(issues []JiraIssue)) error {
+	for nextPageToken := ""; ; {
+		searchUrl := fmt.Sprintf("%s/rest/api/3/search/jql?jql=%s&fields=%s&maxResults=%d",
+			p.URL, url.QueryEscape(p.JQL), jiraSearchFields, p.ResultsLimit)
+		if nextPageToken != "" {
+			searchUrl += "&nextPageToken=" + url.QueryEscape(nextPageToken)
+		}
+		body, _, err := p.request(searchUrl)
+		if err != nil {
+			return fmt.Errorf("unexpected error searching issues with jql: %w", err)
+		}
+
+		response := JiraSearchJqlResponse{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+		handle(response.Issues)
+
+		nextPageToken = response.NextPageToken
+		if response.IsLast || nextPageToken == "" {
+			return nil
+		}
+	}
+}
+
+func (p *JiraPlugin) getIssueItems(issue JiraIssue) {
+	issueUrl := p.getIssueUrl(issue)
+	metadata := map[string]string{jiraContentTypeKey: jiraTypeIssue, jiraProjectKey: issue.Fields.Project.Key}
+
+	p.Items <- Item{
+		Content:  strings.Join([]string{issue.Fields.Summary, string(issue.Fields.Description)}, "\n"),
+		ID:       issueUrl,
+		Metadata: metadata,
+	}
+
+	if p.Comments {
+		comments, err := p.getComments(issue)
+		if err != nil {
+			log.Warn().Msgf("Skipping comments of issue %s: %s", issue.Key, err)
+		}
+		for _, comment := range comments {
+			p.Items <- Item{
+				Content:  string(comment.Body),
+				ID:       fmt.Sprintf("%s?focusedCommentId=%s", issueUrl, comment.ID),
+				Metadata: map[string]string{jiraContentTypeKey: jiraTypeComment, jiraProjectKey: issue.Fields.Project.Key},
+			}
+		}
+	}
+
+	if p.Attachments {
+		for _, attachment := range issue.Fields.Attachment {
+			item, err := p.getAttachmentItem(attachment)
+			if err != nil {
+				log.Warn().Msgf("Skipping attachment %s of issue %s: %s", attachment.Filename, issue.Key, err)
+				continue
+			}
+			item.Metadata[jiraProjectKey] = issue.Fields.Project.Key
+			p.Items <- *item
+		}
+	}
+}
+
+func (p *JiraPlugin) getIssueUrl(issue JiraIssue) string {
+	return fmt.Sprintf("%s/browse/%s", p.URL, issue.Key)
+}
+
+// getComments returns the comments of the issue, the search returns only the first ones
+func (p *JiraPlugin) getComments(issue JiraIssue) ([]JiraComment, error) {
+	comments := issue.Fields.Comment.Comments
+	for len(comments) < issue.Fields.Comment.Total {
+		commentsUrl := fmt.Sprintf("%s/rest/api/2/issue/%s/comment?startAt=%d&maxResults=%d", p.URL, issue.Key, len(comments), p.ResultsLimit)
+		body, _, err := p.request(commentsUrl)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected error getting comments of issue %s: %w", issue.Key, err)
+		}
+
+		response := JiraComments{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return nil, fmt.Errorf("could not unmarshal response %w", err)
+		}
+		if len(response.Comments) == 0 {
+			break
+		}
+		comments = append(comments, response.Comments...)
+	}
+	return comments, nil
+}
+
+func (p *JiraPlugin) getAttachmentItem(attachment JiraAttachment) (*Item, error) {
+	if attachment.Size > maxAttachmentSize {
+		return nil, fmt.Errorf("attachment size %d exceeds the limit of %d bytes", attachment.Size, maxAttachmentSize)
+	}
+
+	body, _, err := p.request(attachment.Content)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error downloading attachment %w", err)
+	}
+
+	content, err := getAttachmentContent(attachment.Filename, attachment.MimeType, body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Item{
+		Content:  content,
+		ID:       attachment.Content,
+		Metadata: map[string]string{jiraContentTypeKey: jiraTypeAttachment},
+	}, nil
+}
+
+type JiraServerInfo struct {
+	DeploymentType string `json:"deploymentType"`
+}
+
+type JiraSearchResponse struct {
+	StartAt    int         `json:"startAt"`
+	MaxResults int         `json:"maxResults"`
+	Total      int         `json:"total"`
+	Issues     []JiraIssue `json:"issues"`
+}
+
+type JiraSearchJqlResponse struct {
+	NextPageToken string      `json:"nextPageToken"`
+	IsLast        bool        `json:"isLast"`
+	Issues        []JiraIssue `json:"issues"`
+}
+
+type JiraIssue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"`
+	Fields struct {
+		Summary     string   `json:"summary"`
+		Description JiraText `json:"description"`
+		Project     struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Comment    JiraComments     `json:"comment"`
+		Attachment []JiraAttachment `json:"attachment"`
+	} `json:"fields"`
+}
+
+type JiraComments struct {
+	StartAt  int           `json:"startAt"`
+	Total    int           `json:"total"`
+	Comments []JiraComment `json:"comments"`
+}
+
+type JiraComment struct {
+	ID   string   `json:"id"`
+	Body JiraText `json:"body"`
+}
+
+type JiraAttachment struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	MimeType string `json:"mimeType"`
+	Size     int    `json:"size"`
+	Content  string `json:"content"`
+}
+
+// JiraText is a text field, returned as a string by the API v2 and as an Atlassian Document Format tree by the API v3
+// https://developer.atlassian.com/cloud/jira/platform/apis/document/structure/
+type JiraText string
+
+func (t *JiraText) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*t = JiraText(text)
+		return nil
+	}
+
+	document := JiraDocumentNode{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	*t = JiraText(document.getText())
+	return nil
+}
+
+type JiraDocumentNode struct {
+	Type    string             `json:"type"`
+	Text    string             `json:"text"`
+	Attrs   JiraDocumentAttrs  `json:"attrs"`
+	Content []JiraDocumentNode `json:"content"`
+}
+
+// JiraDocumentAttrs are the attributes of the inline nodes holding text outside of a text node
+type JiraDocumentAttrs struct {
+	// link of an inlineCard node
+	URL string `json:"url"`
+	// user name of a mention node
+	Text string `json:"text"`
+}
+
+// Inline nodes of the Atlassian Document Format, joined without separator
+var jiraInlineNodes = map[string]bool{
+	"text": true, "hardBreak": true, "inlineCard": true, "mention": true,
+}
+
+// getText joins the inline nodes of a paragraph, and the blocks with new lines
+func (n JiraDocumentNode) getText() string {
+	switch n.Type {
+	case "text":
+		return n.Text
+	case "hardBreak":
+		return "\n"
+	case "inlineCard":
+		return n.Attrs.URL
+	case "mention":
+		return n.Attrs.Text
+	}
+
+	separator := "\n"
+	texts := []string{}
+	for _, child := range n.Content {
+		if jiraInlineNodes[child.Type] {
+			separator = ""
+		}
+		texts = append(texts, child.getText())
+	}
+	return strings.Join(texts, separator)
+}
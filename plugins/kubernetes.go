@@ -0,0 +1,814 @@
+package plugins
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	kubernetesKubeconfigFlag = "kubeconfig"
+	kubernetesContextFlag    = "context"
+	kubernetesNamespaceFlag  = "namespace"
+	kubernetesPathFlag       = "path"
+)
+
+const (
+	kubernetesPageSize     = 500
+	kubernetesKindKey      = "kind"
+	kubernetesNamespaceKey = "namespace"
+	// The release secrets of Helm 3 https://helm.sh/docs/topics/advanced/#storage-backends
+	kubernetesHelmSecretType = "helm.sh/release.v1"
+)
+
+var yamlDocumentSeparatorRegex = regexp.MustCompile(`(?m)^---.*$`)
+
+// kubernetesResource is a resource listed by the plugin in a live cluster
+type kubernetesResource struct {
+	kind string
+	path string
+}
+
+var kubernetesResources = []kubernetesResource{
+	{"ConfigMap", "api/v1/configmaps"},
+	{"Pod", "api/v1/pods"},
+	{"Deployment", "apis/apps/v1/deployments"},
+	{"StatefulSet", "apis/apps/v1/statefulsets"},
+	{"DaemonSet", "apis/apps/v1/daemonsets"},
+	{"CronJob", "apis/batch/v1/cronjobs"},
+}
+
+type KubernetesPlugin struct {
+	Plugin
+	Channels
+
+	Kubeconfig string
+	Context    string
+	Namespaces []string
+	Path       string
+
+	client *kubernetesClient
+}
+
+func (p *KubernetesPlugin) GetName() string {
+	return "kubernetes"
+}
+
+func (p *KubernetesPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s [--%s CONTEXT | --%s PATH]", p.GetName(), kubernetesContextFlag, kubernetesPathFlag),
+		Short: "Scan Kubernetes cluster or manifests",
+		Long: "Scan the ConfigMaps, the environment variables of the Pods and workloads, the annotations and the Helm releases of a Kubernetes cluster for sensitive information. " +
+			"With the path flag, the manifests and Helm charts of a directory are scanned instead, without connecting to a cluster.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if p.Path == "" {
+				if err := p.initialize(); err != nil {
+					p.Errors <- fmt.Errorf("error while initializing kubernetes plugin: %w", err)
+					return
+				}
+			}
+			log.Info().Msg("Kubernetes plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Kubeconfig, kubernetesKubeconfigFlag, "", "Path of the kubeconfig file (default the files of $KUBECONFIG merged, or ~/.kube/config). The auth-provider credentials are not supported, use a token, a client certificate or an exec credential plugin")
+	flags.StringVar(&p.Context, kubernetesContextFlag, "", "Kubeconfig context of the cluster to scan (default the current context)")
+	flags.StringArrayVar(&p.Namespaces, kubernetesNamespaceFlag, []string{}, "Namespaces to scan. If not provided, all the namespaces will be scanned")
+	flags.StringVar(&p.Path, kubernetesPathFlag, "", "Scan the manifests (YAML or JSON) and Helm charts of a directory or a file instead of a cluster")
+	command.MarkFlagsMutuallyExclusive(kubernetesPathFlag, kubernetesContextFlag)
+	command.MarkFlagsMutuallyExclusive(kubernetesPathFlag, kubernetesKubeconfigFlag)
+
+	return command, nil
+}
+
+func (p *KubernetesPlugin) initialize() error {
+	paths := []string{p.Kubeconfig}
+	if p.Kubeconfig == "" {
+		paths = getDefaultKubeconfigs()
+	}
+	config, err := loadKubeconfig(paths)
+	if err != nil {
+		return err
+	}
+
+	if p.Context == "" {
+		p.Context = config.CurrentContext
+	}
+	p.client, err = newKubernetesClient(config, p.Context)
+	return err
+}
+
+func getDefaultKubeconfigs() []string {
+	paths := []string{}
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) > 0 {
+		return paths
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return []string{filepath.Join(".kube", "config")}
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// loadKubeconfig merges the kubeconfig files like kubectl: the first file setting the current context or defining
+// a name wins, and the missing files of a list are ignored
+func loadKubeconfig(paths []string) (Kubeconfig, error) {
+	merged := Kubeconfig{}
+	names := map[string]bool{}
+	loaded := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if len(paths) > 1 && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return merged, fmt.Errorf("error while reading kubeconfig: %w", err)
+		}
+		config := Kubeconfig{}
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return merged, fmt.Errorf("could not unmarshal kubeconfig %s %w", path, err)
+		}
+		config.resolvePaths(filepath.Dir(path))
+		loaded++
+
+		if merged.CurrentContext == "" {
+			merged.CurrentContext = config.CurrentContext
+		}
+		for _, cluster := range config.Clusters {
+			if !names["cluster:"+cluster.Name] {
+				names["cluster:"+cluster.Name] = true
+				merged.Clusters = append(merged.Clusters, cluster)
+			}
+		}
+		for _, user := range config.Users {
+			if !names["user:"+user.Name] {
+				names["user:"+user.Name] = true
+				merged.Users = append(merged.Users, user)
+			}
+		}
+		for _, context := range config.Contexts {
+			if !names["context:"+context.Name] {
+				names["context:"+context.Name] = true
+				merged.Contexts = append(merged.Contexts, context)
+			}
+		}
+	}
+	if loaded == 0 {
+		return merged, fmt.Errorf("error while reading kubeconfig: none of the files %s exists", strings.Join(paths, ", "))
+	}
+	return merged, nil
+}
+
+func (p *KubernetesPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		var err error
+		if p.Path != "" {
+			err = p.getManifestsItems()
+		} else {
+			err = p.getClusterItems()
+		}
+		if err != nil {
+			p.Errors <- err
+		}
+	}()
+}
+
+func (p *KubernetesPlugin) getClusterItems() error {
+	namespaces := p.Namespaces
+	if len(namespaces) == 0 {
+		// the resources of all the namespaces are listed together
+		namespaces = []string{""}
+	}
+
+	for _, namespace := range namespaces {
+		for _, resource := range kubernetesResources {
+			err := p.client.list(resource.path, namespace, "", func(object KubernetesObject) {
+				object.Kind = resource.kind
+				p.sendObjectItems(p.Context, object)
+			})
+			if err != nil {
+				return fmt.Errorf("unexpected error listing %s: %w", resource.kind, err)
+			}
+		}
+
+		err := p.client.list("api/v1/secrets", namespace, "type="+kubernetesHelmSecretType, func(object KubernetesObject) {
+			if err := p.sendHelmReleaseItems(p.Context, object); err != nil {
+				log.Warn().Msgf("Skipping Helm release %s/%s: %s", object.Metadata.Namespace, object.Metadata.Name, err)
+			}
+		})
+		if err != nil {
+			return fmt.Errorf("unexpected error listing Helm releases: %w", err)
+		}
+	}
+	return nil
+}
+
+// getKubernetesItemId identifies the part of an object, with the source (context or file) where the object was found
+func getKubernetesItemId(source string, object KubernetesObject, part string) string {
+	namespace := object.Metadata.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	return fmt.Sprintf("%s:%s/%s/%s#%s", source, namespace, object.Kind, object.Metadata.Name, part)
+}
+
+func (p *KubernetesPlugin) sendItem(source string, object KubernetesObject, part string, content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	p.Items <- Item{
+		Content:  content,
+		ID:       getKubernetesItemId(source, object, part),
+		Metadata: map[string]string{kubernetesKindKey: object.Kind, kubernetesNamespaceKey: object.Metadata.Namespace},
+	}
+}
+
+// sendObjectItems sends the annotations of an object, the data of the ConfigMaps and the environment variables of the pods templates
+func (p *KubernetesPlugin) sendObjectItems(source string, object KubernetesObject) {
+	if object.Kind == "List" {
+		for _, item := range object.Items {
+			p.sendObjectItems(source, item)
+		}
+		return
+	}
+
+	p.sendItem(source, object, "annotations", formatKubernetesMap(object.Metadata.Annotations, ": "))
+
+	if object.Kind == "ConfigMap" {
+		for _, key := range getSortedKeys(object.Data) {
+			p.sendItem(source, object, "data."+key, object.Data[key])
+		}
+		return
+	}
+
+	podSpec := object.getPodSpec()
+	if podSpec == nil {
+		return
+	}
+	containers := append([]KubernetesContainer{}, podSpec.InitContainers...)
+	for _, container := range append(containers, podSpec.Containers...) {
+		env := map[string]string{}
+		for _, variable := range container.Env {
+			env[variable.Name] = variable.Value
+		}
+		p.sendItem(source, object, "env."+container.Name, formatKubernetesMap(env, "="))
+	}
+}
+
+func getSortedKeys(values map[string]string) []string {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatKubernetesMap(values map[string]string, separator string) string {
+	lines := []string{}
+	for _, key := range getSortedKeys(values) {
+		if values[key] != "" {
+			lines = append(lines, key+separator+values[key])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sendHelmReleaseItems sends the values and the rendered manifest of a Helm release,
+// stored in the release secret as base64 encoded gzipped JSON, encoded again as secret data
+func (p *KubernetesPlugin) sendHelmReleaseItems(source string, secret KubernetesObject) error {
+	data, ok := secret.Data["release"]
+	if !ok {
+		return fmt.Errorf("release data not found")
+	}
+	encoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return fmt.Errorf("error while decoding secret data: %w", err)
+	}
+	compressed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return fmt.Errorf("error while decoding release: %w", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("error while uncompressing release: %w", err)
+	}
+	defer reader.Close()
+
+	release := HelmRelease{}
+	if err := json.NewDecoder(reader).Decode(&release); err != nil {
+		return fmt.Errorf("could not unmarshal release %w", err)
+	}
+
+	object := KubernetesObject{Kind: "HelmRelease"}
+	object.Metadata.Name = fmt.Sprintf("%s.v%d", release.Name, release.Version)
+	object.Metadata.Namespace = release.Namespace
+	if len(release.Config) > 0 {
+		values, err := yaml.Marshal(release.Config)
+		if err != nil {
+			return fmt.Errorf("error while converting release values: %w", err)
+		}
+		p.sendItem(source, object, "values", string(values))
+	}
+	p.sendItem(source, object, "manifest", release.Manifest)
+	return nil
+}
+
+// getManifestsItems walks the path, the files of the Helm charts templates and values are sent as is because the templates cannot be parsed before being rendered
+func (p *KubernetesPlugin) getManifestsItems() error {
+	return filepath.Walk(p.Path, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if isIgnoredFolder(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		extension := strings.ToLower(filepath.Ext(filePath))
+		if extension != ".yaml" && extension != ".yml" && extension != ".json" && extension != ".tpl" {
+			return nil
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+
+		if isHelmChartFile(filePath) {
+			if len(bytes.TrimSpace(data)) > 0 {
+				p.Items <- Item{Content: string(data), ID: filePath, Metadata: map[string]string{kubernetesKindKey: "HelmChart"}}
+			}
+			return nil
+		}
+		p.sendManifestItems(filePath, data)
+		return nil
+	})
+}
+
+// isHelmChartFile returns true for the values files and the templates of a chart, found next to its Chart.yaml
+func isHelmChartFile(filePath string) bool {
+	dir := filepath.Dir(filePath)
+	if strings.HasPrefix(filepath.Base(filePath), "values") {
+		return fileExists(filepath.Join(dir, "Chart.yaml"))
+	}
+	for ; dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		if filepath.Base(dir) == "templates" {
+			return fileExists(filepath.Join(filepath.Dir(dir), "Chart.yaml"))
+		}
+	}
+	return false
+}
+
+func fileExists(filePath string) bool {
+	_, err := os.Stat(filePath)
+	return err == nil
+}
+
+func (p *KubernetesPlugin) sendManifestItems(filePath string, data []byte) {
+	for i, document := range yamlDocumentSeparatorRegex.Split(string(data), -1) {
+		if strings.TrimSpace(document) == "" {
+			continue
+		}
+		object := KubernetesObject{}
+		// JSON is valid YAML
+		if err := yaml.Unmarshal([]byte(document), &object); err != nil || object.Kind == "" {
+			log.Debug().Msgf("Scanning document %d of %s as text, it is not a Kubernetes object", i+1, filePath)
+			p.Items <- Item{Content: document, ID: fmt.Sprintf("%s#%d", filePath, i+1)}
+			continue
+		}
+		p.sendObjectItems(filePath, object)
+	}
+}
+
+// kubernetesClient requests the API server of a kubeconfig context
+type kubernetesClient struct {
+	server     string
+	httpClient *http.Client
+	token      string
+	username   string
+	password   string
+	// exec renews the token when it expires or is rejected, when the token was returned by a credential plugin
+	exec            *KubeconfigExec
+	tokenExpiration time.Time
+}
+
+func newKubernetesClient(config Kubeconfig, contextName string) (*kubernetesClient, error) {
+	var context *KubeconfigContext
+	for i := range config.Contexts {
+		if config.Contexts[i].Name == contextName {
+			context = &config.Contexts[i].Context
+		}
+	}
+	if context == nil {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig", contextName)
+	}
+
+	var cluster *KubeconfigCluster
+	for i := range config.Clusters {
+		if config.Clusters[i].Name == context.Cluster {
+			cluster = &config.Clusters[i].Cluster
+		}
+	}
+	if cluster == nil {
+		return nil, fmt.Errorf("cluster '%s' not found in kubeconfig", context.Cluster)
+	}
+	user := KubeconfigUser{}
+	for _, namedUser := range config.Users {
+		if namedUser.Name == context.User {
+			user = namedUser.User
+		}
+	}
+	if user.AuthProvider != nil {
+		return nil, fmt.Errorf("the auth-provider credentials of user '%s' are not supported, use a token, a client certificate or an exec credential plugin", context.User)
+	}
+
+	readData := func(data string, file string) ([]byte, error) {
+		if data != "" {
+			return base64.StdEncoding.DecodeString(data)
+		}
+		if file == "" {
+			return nil, nil
+		}
+		return os.ReadFile(file)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cluster.InsecureSkipTlsVerify}
+	ca, err := readData(cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading cluster certificate authority: %w", err)
+	}
+	if ca != nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("invalid cluster certificate authority")
+		}
+	}
+
+	certificate, err := readData(user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading client certificate: %w", err)
+	}
+	key, err := readData(user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading client key: %w", err)
+	}
+
+	token := user.Token
+	if token == "" && user.TokenFile != "" {
+		data, err := readData("", user.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("error while reading token file: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	var tokenExec *KubeconfigExec
+	var tokenExpiration time.Time
+	if user.Exec != nil {
+		credential, err := getExecCredential(user.Exec)
+		if err != nil {
+			return nil, err
+		}
+		if credential.Status.Token != "" {
+			token = credential.Status.Token
+			tokenExec = user.Exec
+			tokenExpiration = credential.Status.ExpirationTimestamp
+		}
+		// the plugins return PEM data, not base64 data like the kubeconfig
+		if credential.Status.ClientCertificateData != "" && credential.Status.ClientKeyData != "" {
+			certificate = []byte(credential.Status.ClientCertificateData)
+			key = []byte(credential.Status.ClientKeyData)
+		}
+	}
+
+	if certificate != nil && key != nil {
+		keyPair, err := tls.X509KeyPair(certificate, key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{keyPair}
+	}
+
+	return &kubernetesClient{
+		server:          strings.TrimRight(cluster.Server, "/"),
+		httpClient:      &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment}},
+		token:           token,
+		username:        user.Username,
+		password:        user.Password,
+		exec:            tokenExec,
+		tokenExpiration: tokenExpiration,
+	}, nil
+}
+
+// getExecCredential runs the credential plugin of the user, like the CLIs of the cloud providers, and returns its credential.
+// The client runs it again to renew an expired token, the client certificate it returns is not renewed.
+// https://kubernetes.io/docs/reference/access-authn-authz/authentication/#client-go-credential-plugins
+func getExecCredential(config *KubeconfigExec) (*ExecCredential, error) {
+	info, err := json.Marshal(map[string]interface{}{
+		"apiVersion": config.APIVersion,
+		"kind":       "ExecCredential",
+		"spec":       map[string]interface{}{"interactive": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	command := exec.Command(config.Command, config.Args...)
+	command.Env = append(os.Environ(), "KUBERNETES_EXEC_INFO="+string(info))
+	for _, env := range config.Env {
+		command.Env = append(command.Env, env.Name+"="+env.Value)
+	}
+	command.Stderr = os.Stderr
+	output, err := command.Output()
+	if err != nil {
+		return nil, fmt.Errorf("error while running credential plugin %s: %w", config.Command, err)
+	}
+
+	credential := ExecCredential{}
+	if err := json.Unmarshal(output, &credential); err != nil {
+		return nil, fmt.Errorf("could not unmarshal credential plugin output %w", err)
+	}
+	return &credential, nil
+}
+
+// list requests the pages of a resource list, in a namespace or in all the namespaces, calling handle for each object
+func (c *kubernetesClient) list(resourcePath string, namespace string, fieldSelector string, handle func(object KubernetesObject)) error {
+	listPath := resourcePath
+	if namespace != "" {
+		group, resource := filepath.Split(resourcePath)
+		listPath = fmt.Sprintf("%snamespaces/%s/%s", group, url.PathEscape(namespace), resource)
+	}
+
+	for continueToken := ""; ; {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprint(kubernetesPageSize))
+		if continueToken != "" {
+			query.Set("continue", continueToken)
+		}
+		if fieldSelector != "" {
+			query.Set("fieldSelector", fieldSelector)
+		}
+
+		body, err := c.get(fmt.Sprintf("%s/%s?%s", c.server, listPath, query.Encode()))
+		if err != nil {
+			return err
+		}
+		list := KubernetesList{}
+		if err := json.Unmarshal(body, &list); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+		for _, object := range list.Items {
+			handle(object)
+		}
+
+		continueToken = list.Metadata.Continue
+		if continueToken == "" {
+			return nil
+		}
+	}
+}
+
+// renewExecToken runs the credential plugin again, when the token has expired or was rejected by the API server
+func (c *kubernetesClient) renewExecToken() error {
+	credential, err := getExecCredential(c.exec)
+	if err != nil {
+		return err
+	}
+	if credential.Status.Token == "" {
+		return fmt.Errorf("credential plugin %s returned no token", c.exec.Command)
+	}
+	c.token = credential.Status.Token
+	c.tokenExpiration = credential.Status.ExpirationTimestamp
+	return nil
+}
+
+func (c *kubernetesClient) get(requestUrl string) ([]byte, error) {
+	if c.exec != nil && !c.tokenExpiration.IsZero() && !time.Now().Before(c.tokenExpiration) {
+		log.Debug().Msgf("Renewing the expired token of credential plugin %s", c.exec.Command)
+		if err := c.renewExecToken(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, statusCode, err := c.send(requestUrl)
+	if err == nil && statusCode == http.StatusUnauthorized && c.exec != nil {
+		log.Debug().Msgf("Renewing the rejected token of credential plugin %s", c.exec.Command)
+		if err := c.renewExecToken(); err != nil {
+			return nil, err
+		}
+		body, statusCode, err = c.send(requestUrl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, fmt.Errorf("error calling kubernetes url \"%s\". status code: %d, %s", requestUrl, statusCode, body)
+	}
+	return body, nil
+}
+
+func (c *kubernetesClient) send(requestUrl string) ([]byte, int, error) {
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected error creating an http request %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" {
+		request.SetBasicAuth(c.username, c.password)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to send http request %w", err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unexpected error reading http response body %w", err)
+	}
+	return body, response.StatusCode, nil
+}
+
+type Kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string            `yaml:"name"`
+		Cluster KubeconfigCluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string         `yaml:"name"`
+		User KubeconfigUser `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string            `yaml:"name"`
+		Context KubeconfigContext `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// resolvePaths makes the files paths of the kubeconfig absolute, they are relative to its directory
+func (c *Kubeconfig) resolvePaths(dir string) {
+	resolve := func(file *string) {
+		if *file != "" && !filepath.IsAbs(*file) {
+			*file = filepath.Join(dir, *file)
+		}
+	}
+	for i := range c.Clusters {
+		resolve(&c.Clusters[i].Cluster.CertificateAuthority)
+	}
+	for i := range c.Users {
+		user := &c.Users[i].User
+		resolve(&user.ClientCertificate)
+		resolve(&user.ClientKey)
+		resolve(&user.TokenFile)
+		// the commands without a path are searched in the PATH
+		if user.Exec != nil && strings.ContainsAny(user.Exec.Command, `/\`) {
+			resolve(&user.Exec.Command)
+		}
+	}
+}
+
+type KubeconfigCluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTlsVerify    bool   `yaml:"insecure-skip-tls-verify"`
+}
+
+type KubeconfigUser struct {
+	Token                 string          `yaml:"token"`
+	TokenFile             string          `yaml:"tokenFile"`
+	Username              string          `yaml:"username"`
+	Password              string          `yaml:"password"`
+	ClientCertificate     string          `yaml:"client-certificate"`
+	ClientCertificateData string          `yaml:"client-certificate-data"`
+	ClientKey             string          `yaml:"client-key"`
+	ClientKeyData         string          `yaml:"client-key-data"`
+	Exec                  *KubeconfigExec `yaml:"exec"`
+	AuthProvider          interface{}     `yaml:"auth-provider"`
+}
+
+type KubeconfigExec struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+}
+
+type ExecCredential struct {
+	Status struct {
+		Token                 string    `json:"token"`
+		ExpirationTimestamp   time.Time `json:"expirationTimestamp"`
+		ClientCertificateData string    `json:"clientCertificateData"`
+		ClientKeyData         string    `json:"clientKeyData"`
+	} `json:"status"`
+}
+
+type KubeconfigContext struct {
+	Cluster string `yaml:"cluster"`
+	User    string `yaml:"user"`
+}
+
+type KubernetesList struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+	Items []KubernetesObject `json:"items"`
+}
+
+// KubernetesObject holds the fields of the scanned objects, it is decoded from the API JSON or from the manifests YAML
+type KubernetesObject struct {
+	Kind     string `json:"kind" yaml:"kind"`
+	Metadata struct {
+		Name        string            `json:"name" yaml:"name"`
+		Namespace   string            `json:"namespace" yaml:"namespace"`
+		Annotations map[string]string `json:"annotations" yaml:"annotations"`
+	} `json:"metadata" yaml:"metadata"`
+	// The data of the ConfigMaps, or the base64 encoded data of the Secrets
+	Data  map[string]string  `json:"data" yaml:"data"`
+	Spec  KubernetesSpec     `json:"spec" yaml:"spec"`
+	Items []KubernetesObject `json:"items" yaml:"items"`
+}
+
+// KubernetesSpec is the spec of a Pod, or of a workload with a pod template
+type KubernetesSpec struct {
+	KubernetesPodSpec `json:",inline" yaml:",inline"`
+	Template          *struct {
+		Spec *KubernetesPodSpec `json:"spec" yaml:"spec"`
+	} `json:"template" yaml:"template"`
+	JobTemplate *struct {
+		Spec *KubernetesSpec `json:"spec" yaml:"spec"`
+	} `json:"jobTemplate" yaml:"jobTemplate"`
+}
+
+type KubernetesPodSpec struct {
+	Containers     []KubernetesContainer `json:"containers" yaml:"containers"`
+	InitContainers []KubernetesContainer `json:"initContainers" yaml:"initContainers"`
+}
+
+type KubernetesContainer struct {
+	Name string `json:"name" yaml:"name"`
+	Env  []struct {
+		Name  string `json:"name" yaml:"name"`
+		Value string `json:"value" yaml:"value"`
+	} `json:"env" yaml:"env"`
+}
+
+func (o *KubernetesObject) getPodSpec() *KubernetesPodSpec {
+	switch {
+	case o.Kind == "Pod":
+		return &o.Spec.KubernetesPodSpec
+	case o.Spec.Template != nil:
+		return o.Spec.Template.Spec
+	case o.Spec.JobTemplate != nil && o.Spec.JobTemplate.Spec != nil && o.Spec.JobTemplate.Spec.Template != nil:
+		return o.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+type HelmRelease struct {
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace"`
+	Version   int                    `json:"version"`
+	Config    map[string]interface{} `json:"config"`
+	Manifest  string                 `json:"manifest"`
+}
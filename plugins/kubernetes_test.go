@@ -0,0 +1,358 @@
+package plugins
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func scanKubernetes(t *testing.T, p *KubernetesPlugin) []Item {
+	p.Channels = Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+func assertKubernetesItems(t *testing.T, items []Item, expected []Item) {
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+	}
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const kubernetesTestManifests = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: prod
+data:
+  DB_URL: postgres://admin:123@db/app
+  settings.ini: |
+    [auth]
+    token = abc
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  annotations:
+    owner: team-a
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        env:
+        - name: DB_PASSWORD
+          value: "123"
+      containers:
+      - name: api
+        env:
+        - name: API_KEY
+          value: abc
+        - name: FROM_SECRET
+          valueFrom:
+            secretKeyRef:
+              name: api
+              key: key
+`
+
+func TestKubernetesManifests(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"manifests/app.yaml": kubernetesTestManifests,
+		"manifests/jobs.json": `{"kind": "List", "items": [{"kind": "CronJob", "metadata": {"name": "backup"},
+			"spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"name": "backup", "env": [{"name": "S3_SECRET", "value": "xyz"}]}]}}}}}}]}`,
+		"manifests/notes.txt":               "not a manifest",
+		"chart/Chart.yaml":                  "name: app\nversion: 1.0.0\n",
+		"chart/values.yaml":                 "password: changeme\n",
+		"chart/templates/secret.yaml":       "apiVersion: v1\nkind: Secret\nstringData:\n  token: {{ .Values.password }}\n",
+		"chart/.git/config":                 "token=ignored",
+		"chart/templates/.git/ignored.yaml": "token: ignored",
+	})
+
+	items := scanKubernetes(t, &KubernetesPlugin{Path: dir})
+
+	manifests := filepath.Join(dir, "manifests")
+	assertKubernetesItems(t, items, []Item{
+		{Content: "name: app\nversion: 1.0.0\n", ID: filepath.Join(dir, "chart", "Chart.yaml") + "#1"},
+		{Content: "apiVersion: v1\nkind: Secret\nstringData:\n  token: {{ .Values.password }}\n", ID: filepath.Join(dir, "chart", "templates", "secret.yaml")},
+		{Content: "password: changeme\n", ID: filepath.Join(dir, "chart", "values.yaml")},
+		{Content: "owner: team-a", ID: filepath.Join(manifests, "app.yaml") + ":default/Deployment/api#annotations"},
+		{Content: "API_KEY=abc", ID: filepath.Join(manifests, "app.yaml") + ":default/Deployment/api#env.api"},
+		{Content: "DB_PASSWORD=123", ID: filepath.Join(manifests, "app.yaml") + ":default/Deployment/api#env.migrate"},
+		{Content: "postgres://admin:123@db/app", ID: filepath.Join(manifests, "app.yaml") + ":prod/ConfigMap/app-config#data.DB_URL"},
+		{Content: "[auth]\ntoken = abc\n", ID: filepath.Join(manifests, "app.yaml") + ":prod/ConfigMap/app-config#data.settings.ini"},
+		{Content: "S3_SECRET=xyz", ID: filepath.Join(manifests, "jobs.json") + ":default/CronJob/backup#env.backup"},
+	})
+}
+
+func encodeHelmRelease(t *testing.T, release map[string]interface{}) string {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	if err := json.NewEncoder(writer).Encode(release); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	helmEncoded := base64.StdEncoding.EncodeToString(buffer.Bytes())
+	return base64.StdEncoding.EncodeToString([]byte(helmEncoded))
+}
+
+func TestKubernetesCluster(t *testing.T) {
+	release := encodeHelmRelease(t, map[string]interface{}{
+		"name": "app", "namespace": "prod", "version": 2,
+		"config":   map[string]interface{}{"db": map[string]string{"password": "123"}},
+		"manifest": "kind: Secret\nstringData:\n  password: 123\n",
+	})
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer cluster-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var response string
+		switch r.URL.Path {
+		case "/api/v1/namespaces/prod/configmaps":
+			if r.URL.Query().Get("continue") == "" {
+				response = `{"metadata": {"continue": "next"}, "items": [{"metadata": {"name": "app", "namespace": "prod"}, "data": {"DB_URL": "postgres://admin:123@db"}}]}`
+			} else {
+				response = `{"metadata": {}, "items": [{"metadata": {"name": "empty", "namespace": "prod"}}]}`
+			}
+		case "/api/v1/namespaces/prod/pods":
+			response = `{"items": [{"metadata": {"name": "api-1", "namespace": "prod", "annotations": {"kubectl.kubernetes.io/last-applied-configuration": "{\"token\": \"abc\"}"}},
+				"spec": {"containers": [{"name": "api", "env": [{"name": "API_KEY", "value": "abc"}]}]}}]}`
+		case "/apis/apps/v1/namespaces/prod/deployments":
+			response = `{"items": [{"metadata": {"name": "api", "namespace": "prod"},
+				"spec": {"template": {"spec": {"containers": [{"name": "api", "env": [{"name": "API_KEY", "value": "abc"}]}]}}}}]}`
+		case "/apis/apps/v1/namespaces/prod/statefulsets", "/apis/apps/v1/namespaces/prod/daemonsets", "/apis/batch/v1/namespaces/prod/cronjobs":
+			response = `{"items": []}`
+		case "/api/v1/namespaces/prod/secrets":
+			if r.URL.Query().Get("fieldSelector") != "type="+kubernetesHelmSecretType {
+				t.Errorf("unexpected field selector %s", r.URL.Query().Get("fieldSelector"))
+			}
+			response = fmt.Sprintf(`{"items": [{"metadata": {"name": "sh.helm.release.v1.app.v2", "namespace": "prod"}, "data": {"release": "%s"}}]}`, release)
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+current-context: other
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority: ca.crt
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: cluster-token
+`, server.URL)
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config": kubeconfig, "ca.crt": string(ca)})
+
+	p := &KubernetesPlugin{Kubeconfig: filepath.Join(dir, "config"), Context: "test", Namespaces: []string{"prod"}}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items := scanKubernetes(t, p)
+
+	assertKubernetesItems(t, items, []Item{
+		{Content: "postgres://admin:123@db", ID: "test:prod/ConfigMap/app#data.DB_URL"},
+		{Content: "API_KEY=abc", ID: "test:prod/Deployment/api#env.api"},
+		{Content: "kind: Secret\nstringData:\n  password: 123\n", ID: "test:prod/HelmRelease/app.v2#manifest"},
+		{Content: "db:\n  password: \"123\"\n", ID: "test:prod/HelmRelease/app.v2#values"},
+		{Content: `kubectl.kubernetes.io/last-applied-configuration: {"token": "abc"}`, ID: "test:prod/Pod/api-1#annotations"},
+		{Content: "API_KEY=abc", ID: "test:prod/Pod/api-1#env.api"},
+	})
+}
+
+func TestKubernetesKubeconfigMergeAndExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the credential plugin is a shell script")
+	}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"first": `current-context: test
+clusters:
+- name: test
+  cluster:
+    server: https://cluster.example.com
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+`,
+		"second": `current-context: other
+clusters:
+- name: test
+  cluster:
+    server: https://ignored.example.com
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: bin/credential.sh
+      env:
+      - name: CLUSTER_TOKEN
+        value: exec-token
+`,
+		"bin/credential.sh": `#!/bin/sh
+echo "{\"kind\": \"ExecCredential\", \"status\": {\"token\": \"$CLUSTER_TOKEN\"}}"
+`,
+	})
+	if err := os.Chmod(filepath.Join(dir, "bin", "credential.sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	paths := []string{filepath.Join(dir, "first"), filepath.Join(dir, "missing"), filepath.Join(dir, "second")}
+	t.Setenv("KUBECONFIG", strings.Join(paths, string(os.PathListSeparator)))
+
+	p := &KubernetesPlugin{}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Context != "test" || p.client.server != "https://cluster.example.com" || p.client.token != "exec-token" {
+		t.Errorf("expected the context of the first file with the exec token of the second, but got %s %s %s", p.Context, p.client.server, p.client.token)
+	}
+}
+
+func TestKubernetesRenewsExecToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the credential plugin is a shell script")
+	}
+
+	tests := []struct {
+		name       string
+		expiration string
+		rejected   int
+	}{
+		{name: "expired token", expiration: `, \"expirationTimestamp\": \"2000-01-01T00:00:00Z\"`, rejected: 0},
+		{name: "rejected token", expiration: "", rejected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer token-2" {
+					rejected++
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"items": []}`))
+			}))
+			defer server.Close()
+
+			dir := t.TempDir()
+			writeFiles(t, dir, map[string]string{
+				"config": fmt.Sprintf(`current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: ./credential.sh
+`, server.URL),
+				"credential.sh": fmt.Sprintf(`#!/bin/sh
+echo x >> "$(dirname "$0")/runs"
+runs=$(wc -l < "$(dirname "$0")/runs" | tr -d ' ')
+echo "{\"kind\": \"ExecCredential\", \"status\": {\"token\": \"token-$runs\"%s}}"
+`, tt.expiration),
+			})
+			if err := os.Chmod(filepath.Join(dir, "credential.sh"), 0700); err != nil {
+				t.Fatal(err)
+			}
+
+			p := &KubernetesPlugin{Kubeconfig: filepath.Join(dir, "config")}
+			if err := p.initialize(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := p.client.get(server.URL + "/api/v1/secrets"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p.client.token != "token-2" || rejected != tt.rejected {
+				t.Errorf("expected the renewed token after %d rejected requests, but got %s after %d", tt.rejected, p.client.token, rejected)
+			}
+		})
+	}
+}
+
+func TestKubernetesContextNotFound(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"config": "current-context: missing\ncontexts: []\n"})
+
+	p := &KubernetesPlugin{Kubeconfig: filepath.Join(dir, "config")}
+	if err := p.initialize(); err == nil || err.Error() != "context 'missing' not found in kubeconfig" {
+		t.Errorf("expected context not found error, but got %v", err)
+	}
+}
@@ -5,8 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/checkmarx/2ms/lib"
@@ -16,15 +19,30 @@ import (
 )
 
 const (
-	paligoInstanceFlag = "instance"
-	paligoUsernameFlag = "username"
-	paligoTokenFlag    = "token"
-	paligoAuthFlag     = "auth"
-	paligoFolderFlag   = "folder"
+	paligoInstanceFlag     = "instance"
+	paligoUsernameFlag     = "username"
+	paligoTokenFlag        = "token"
+	paligoAuthFlag         = "auth"
+	paligoFolderFlag       = "folder"
+	paligoStateFileFlag    = "state-file"
+	paligoBaseUrlFlag      = "base-url"
+	paligoTranslationsFlag = "translations"
+)
+
+// Folders and documents fetched at the same time, the requests are still paced by the folders and documents rate limiters
+const paligoMaxRequests = 5
+
+// Metadata keys of the documents items
+const (
+	paligoLanguageKey      = "language"
+	paligoLanguagesKey     = "languages"
+	paligoReleaseStatusKey = "releaseStatus"
+	paligoModifiedAtKey    = "modifiedAt"
 )
 
 var (
 	paligoInstanceArg string
+	paligoBaseUrlArg  string
 	paligoFolderArg   int
 )
 
@@ -36,6 +54,10 @@ type PaligoPlugin struct {
 	token    string
 	auth     string
 
+	stateFile    string
+	state        *paligoState
+	translations bool
+
 	paligoApi *PaligoClient
 }
 
@@ -43,11 +65,11 @@ func (p *PaligoPlugin) GetCredentials() (string, string) {
 	return p.username, p.token
 }
 
-func (p *PaligoPlugin) GetAuthorizationHeader() string {
+func (p *PaligoPlugin) GetAuthorizationHeader() (string, error) {
 	if p.auth != "" {
-		return fmt.Sprintf("Basic %s", p.auth)
+		return fmt.Sprintf("Basic %s", p.auth), nil
 	}
-	return lib.CreateBasicAuthCredentials(p)
+	return lib.CreateBasicAuthCredentials(p), nil
 }
 
 func (p *PaligoPlugin) GetName() string {
@@ -71,16 +93,17 @@ func (p *PaligoPlugin) DefineCommand(channels Channels) (*cobra.Command, error)
 				p.Channels.Errors <- fmt.Errorf("exactly one of the flags in the group %v must be set; none were set", []string{paligoAuthFlag, paligoUsernameFlag, paligoTokenFlag})
 				return
 			}
+			if paligoInstanceArg == "" && paligoBaseUrlArg == "" {
+				p.Channels.Errors <- fmt.Errorf("at least one of the flags in the group %v is required", []string{paligoInstanceFlag, paligoBaseUrlFlag})
+				return
+			}
 			log.Info().Msg("Paligo plugin started")
 			p.getItems()
 		},
 	}
 
-	command.Flags().StringVar(&paligoInstanceArg, paligoInstanceFlag, "", "Paligo instance name [required]")
-	err := command.MarkFlagRequired(paligoInstanceFlag)
-	if err != nil {
-		return nil, fmt.Errorf("error while marking flag %s as required: %w", paligoInstanceFlag, err)
-	}
+	command.Flags().StringVar(&paligoInstanceArg, paligoInstanceFlag, "", "Paligo instance name")
+	command.Flags().StringVar(&paligoBaseUrlArg, paligoBaseUrlFlag, "", "Paligo base URL, for private deployments (default https://<instance>.paligoapp.com)")
 
 	command.Flags().StringVar(&p.username, paligoUsernameFlag, "", "Paligo username")
 	command.Flags().StringVar(&p.token, paligoTokenFlag, "", "Paligo token")
@@ -91,12 +114,21 @@ func (p *PaligoPlugin) DefineCommand(channels Channels) (*cobra.Command, error)
 	command.MarkFlagsMutuallyExclusive(paligoTokenFlag, paligoAuthFlag)
 
 	command.Flags().IntVar(&paligoFolderArg, paligoFolderFlag, 0, "Paligo folder ID")
+	command.Flags().BoolVar(&p.translations, paligoTranslationsFlag, false, "Scan the translations of the documents in each of their languages")
+	command.Flags().StringVar(&p.stateFile, paligoStateFileFlag, "", "File keeping the modification date of the scanned documents, only the documents modified since the previous scan are scanned")
 
 	return command, nil
 }
 
 func (p *PaligoPlugin) getItems() {
-	p.paligoApi = newPaligoApi(paligoInstanceArg, p)
+	p.paligoApi = newPaligoApi(paligoInstanceArg, paligoBaseUrlArg, p)
+
+	state, err := loadPaligoState(p.stateFile)
+	if err != nil {
+		p.Channels.Errors <- err
+		return
+	}
+	p.state = state
 
 	foldersToProcess, err := p.getFirstProcessingFolders()
 	if err != nil {
@@ -104,13 +136,19 @@ func (p *PaligoPlugin) getItems() {
 		return
 	}
 
-	itemsChan := p.processFolders(foldersToProcess)
+	p.Limit = make(chan struct{}, paligoMaxRequests)
+	foldersWaitGroup := &sync.WaitGroup{}
+	for _, folder := range foldersToProcess {
+		p.processFolder(folder, foldersWaitGroup)
+	}
 
 	p.WaitGroup.Add(1)
 	go func() {
 		defer p.WaitGroup.Done()
-		for item := range itemsChan {
-			p.handleComponent(item)
+		foldersWaitGroup.Wait()
+
+		if err := p.state.save(); err != nil {
+			p.Channels.Errors <- err
 		}
 	}()
 }
@@ -133,56 +171,206 @@ func (p *PaligoPlugin) getFirstProcessingFolders() ([]PaligoItem, error) {
 	return foldersToProcess, nil
 }
 
-func (p *PaligoPlugin) processFolders(foldersToProcess []PaligoItem) chan PaligoItem {
-
-	itemsChan := make(chan PaligoItem)
-
-	p.WaitGroup.Add(1)
+// processFolder gets the folder and then its documents and sub folders, each in a goroutine limited by the
+// number of concurrent requests. A folder or a document that cannot be fetched is skipped.
+func (p *PaligoPlugin) processFolder(folder PaligoItem, foldersWaitGroup *sync.WaitGroup) {
+	foldersWaitGroup.Add(1)
 	go func() {
-		defer p.WaitGroup.Done()
-
-		for len(foldersToProcess) > 0 {
-			folder := foldersToProcess[0]
-			foldersToProcess = foldersToProcess[1:]
+		defer foldersWaitGroup.Done()
 
-			log.Info().Msgf("Getting folder %s", folder.Name)
-			folderInfo, err := p.paligoApi.showFolder(folder.ID)
-			if err != nil {
-				log.Error().Err(err).Msgf("error while getting %s '%s'", folder.Type, folder.Name)
-				p.Channels.Errors <- err
-				continue
-			}
+		log.Info().Msgf("Getting folder %s", folder.Name)
+		p.Limit <- struct{}{}
+		folderInfo, err := p.paligoApi.showFolder(folder.ID)
+		<-p.Limit
+		if err != nil {
+			log.Warn().Msgf("Skipping folder '%s': %s", folder.Name, err)
+			return
+		}
 
-			for _, child := range folderInfo.Children {
-				if child.Type == "component" {
-					itemsChan <- child
-				} else if child.Type == "folder" {
-					foldersToProcess = append(foldersToProcess, child)
-				}
+		for _, child := range folderInfo.Children {
+			if child.Type == "component" {
+				foldersWaitGroup.Add(1)
+				go func(item Component) {
+					defer foldersWaitGroup.Done()
+					p.Limit <- struct{}{}
+					defer func() { <-p.Limit }()
+					p.handleComponent(item)
+				}(child)
+			} else if child.Type == "folder" {
+				p.processFolder(child.PaligoItem, foldersWaitGroup)
 			}
 		}
-		close(itemsChan)
 	}()
-
-	return itemsChan
 }
 
-func (p *PaligoPlugin) handleComponent(item PaligoItem) {
+func (p *PaligoPlugin) handleComponent(item Component) {
+	if p.state.isUnchanged(item) {
+		log.Debug().Msgf("Skipping component %s, not modified since the previous scan", item.Name)
+		return
+	}
 
 	log.Info().Msgf("Getting component %s", item.Name)
-	document, err := p.paligoApi.showDocument(item.ID)
+	document, err := p.paligoApi.showDocument(item.ID, "")
 	if err != nil {
-		log.Error().Err(err).Msgf("error while getting document '%s'", item.Name)
-		p.Channels.Errors <- fmt.Errorf("error while getting document '%s': %w", item.Name, err)
+		log.Warn().Msgf("Skipping document '%s': %s", item.Name, err)
 		return
 	}
 
-	url := fmt.Sprintf("https://%s.paligoapp.com/document/edit/%d", p.paligoApi.Instance, document.ID)
+	url := fmt.Sprintf("%s/document/edit/%d", p.paligoApi.BaseUrl, document.ID)
 
 	p.Items <- Item{
-		Content: document.Content,
-		ID:      url,
+		Content:  document.Content,
+		ID:       url,
+		Metadata: getPaligoDocumentMetadata(item, document),
+	}
+
+	if p.translations {
+		if err := p.handleTranslations(item, document, url); err != nil {
+			// the document is not saved in the state, so its translations are scanned again by the next scan
+			log.Warn().Msgf("Skipping the rest of the translations of document '%s': %s", item.Name, err)
+			return
+		}
+	}
+	p.state.update(item)
+}
+
+// handleTranslations sends the content of the document in each of its languages, except the language already scanned.
+// When the document does not tell its language, all the languages are requested and the copies of its content are skipped.
+func (p *PaligoPlugin) handleTranslations(item Component, document *Document, url string) error {
+	for _, language := range getPaligoDocumentLanguages(item, document) {
+		// the main document request already returned the source language
+		if language == document.Language {
+			continue
+		}
+		translation, err := p.paligoApi.showDocument(document.ID, language)
+		if err != nil {
+			return fmt.Errorf("error while getting %s translation: %w", language, err)
+		}
+		if translation.Language != "" && translation.Language != language {
+			log.Warn().Msgf("Skipping %s translation of document '%s', Paligo returned the %s content instead", language, item.Name, translation.Language)
+			continue
+		}
+		if translation.Content == "" || translation.Content == document.Content {
+			continue
+		}
+
+		metadata := getPaligoDocumentMetadata(item, translation)
+		metadata[paligoLanguageKey] = language
+		p.Items <- Item{
+			Content:  translation.Content,
+			ID:       fmt.Sprintf("%s; Language: %s", url, language),
+			Metadata: metadata,
+		}
+	}
+	return nil
+}
+
+func getPaligoDocumentMetadata(item Component, document *Document) map[string]string {
+	languages := getPaligoDocumentLanguages(item, document)
+	releaseStatus := document.ReleaseStatus
+	if releaseStatus == "" {
+		releaseStatus = item.ReleaseStatus
+	}
+	modifiedAt := document.ModifiedAt
+	if modifiedAt == 0 {
+		modifiedAt = item.ModifiedAt
+	}
+
+	metadata := map[string]string{}
+	if document.Language != "" {
+		metadata[paligoLanguageKey] = document.Language
+	}
+	if len(languages) > 0 {
+		metadata[paligoLanguagesKey] = strings.Join(languages, ", ")
+	}
+	if releaseStatus != "" {
+		metadata[paligoReleaseStatusKey] = releaseStatus
+	}
+	if modifiedAt != 0 {
+		metadata[paligoModifiedAtKey] = time.Unix(int64(modifiedAt), 0).UTC().Format(time.RFC3339)
+	}
+	return metadata
+}
+
+// getPaligoDocumentLanguages returns the languages of the document, in no particular order
+func getPaligoDocumentLanguages(item Component, document *Document) []string {
+	if len(document.Languages) > 0 {
+		return document.Languages
+	}
+	return item.Languages
+}
+
+/**
+ * Paligo state
+ */
+
+// paligoState keeps the modification date of the scanned documents between scans
+type paligoState struct {
+	path string
+	mu   sync.Mutex
+
+	Documents map[int]int `json:"documents"`
+}
+
+func loadPaligoState(path string) (*paligoState, error) {
+	state := &paligoState{path: path, Documents: map[int]int{}}
+	if path == "" {
+		return state, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Info().Msgf("Paligo state file %s not found, all the documents will be scanned", path)
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error while reading paligo state file: %w", err)
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("error while parsing paligo state file: %w", err)
+	}
+	if state.Documents == nil {
+		state.Documents = map[int]int{}
+	}
+	return state, nil
+}
+
+func (s *paligoState) isUnchanged(item Component) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	modifiedAt, ok := s.Documents[item.ID]
+	return ok && item.ModifiedAt != 0 && modifiedAt == item.ModifiedAt
+}
+
+func (s *paligoState) update(item Component) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item.ModifiedAt != 0 {
+		s.Documents[item.ID] = item.ModifiedAt
+	}
+}
+
+// save writes the state in a temporary file first, so an interrupted write keeps the previous state
+func (s *paligoState) save() error {
+	if s.path == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	content, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error while encoding paligo state: %w", err)
+	}
+
+	tmpFile := filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err := os.WriteFile(tmpFile, content, 0600); err != nil {
+		return fmt.Errorf("error while writing paligo state file: %w", err)
 	}
+	if err := os.Rename(tmpFile, s.path); err != nil {
+		return fmt.Errorf("error while writing paligo state file: %w", err)
+	}
+	return nil
 }
 
 /**
@@ -209,7 +397,7 @@ type PaligoItem struct {
 
 type Folder struct {
 	PaligoItem
-	Children []PaligoItem `json:"children"`
+	Children []Component `json:"children"`
 }
 
 type EmptyFolder struct {
@@ -245,65 +433,63 @@ type ListFoldersResponse struct {
 
 type Document struct {
 	PaligoItem
-	Content   string   `json:"content"`
-	Languages []string `json:"languages"`
+	Content       string   `json:"content"`
+	Language      string   `json:"language"`
+	Languages     []string `json:"languages"`
+	ReleaseStatus string   `json:"release_status"`
+	ModifiedAt    int      `json:"modified_at"`
 }
 
 type PaligoClient struct {
 	Instance string
+	BaseUrl  string
 	auth     lib.IAuthorizationHeader
 
 	foldersLimiter   *rate.Limiter
 	documentsLimiter *rate.Limiter
 }
 
-func reserveRateLimit(response *http.Response, lim *rate.Limiter, err error) error {
-	if response.StatusCode != 429 {
-		return err
-	}
-
-	rateLimit := response.Header.Get("Retry-After")
-	if rateLimit == "" {
-		return fmt.Errorf("Retry-After header not found")
-	}
-	seconds, err := strconv.Atoi(rateLimit)
-	if err != nil {
-		return fmt.Errorf("error parsing Retry-After header: %w", err)
+// reserveRateLimit slows down the limiter to one request at a time once Paligo rejected a request
+func reserveRateLimit(lim *rate.Limiter) lib.RetryDelay {
+	return func(response *http.Response, attempt int) (time.Duration, bool) {
+		delay, retry := lib.RetryAfterDelay(response, attempt)
+		if retry {
+			lim.SetBurst(1)
+		}
+		return delay, retry
 	}
-	log.Warn().Msgf("Rate limit exceeded, need to wait for %d seconds", seconds)
-	lim.SetBurst(1)
-	time.Sleep(time.Second * time.Duration(seconds))
-	return nil
 }
 
 func (p *PaligoClient) request(endpoint string, lim *rate.Limiter) ([]byte, error) {
-	if err := lim.Wait(context.Background()); err != nil {
-		log.Error().Msgf("Error waiting for rate limiter: %s", err)
-		return nil, err
-	}
-
-	url := fmt.Sprintf("https://%s.paligoapp.com/api/v2/%s", p.Instance, endpoint)
-	body, response, err := lib.HttpRequest("GET", url, p.auth)
-	if err != nil {
-		if err := reserveRateLimit(response, lim, err); err != nil {
-			return nil, err
+	url := fmt.Sprintf("%s/api/v2/%s", p.BaseUrl, endpoint)
+	body, _, err := lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		if err := lim.Wait(context.Background()); err != nil {
+			log.Error().Msgf("Error waiting for rate limiter: %s", err)
+			return nil, nil, err
 		}
-		return p.request(endpoint, lim)
-	}
-
-	return body, nil
+		return lib.HttpRequest("GET", url, p.auth)
+	}, reserveRateLimit(lim))
+	return body, err
 }
 
 func (p *PaligoClient) listFolders() (*[]EmptyFolder, error) {
-	body, err := p.request("folders", p.foldersLimiter)
-	if err != nil {
-		return nil, err
-	}
+	folders := []EmptyFolder{}
+	for page := 1; ; page++ {
+		body, err := p.request(fmt.Sprintf("folders?page=%d", page), p.foldersLimiter)
+		if err != nil {
+			return nil, err
+		}
 
-	var folders *ListFoldersResponse
-	err = json.Unmarshal(body, &folders)
+		response := &ListFoldersResponse{}
+		if err := json.Unmarshal(body, response); err != nil {
+			return nil, err
+		}
+		folders = append(folders, response.Folders...)
 
-	return &folders.Folders, err
+		if response.NextPage == "" || (response.TotalPages > 0 && page >= response.TotalPages) {
+			return &folders, nil
+		}
+	}
 }
 
 func (p *PaligoClient) showFolder(folderId int) (*Folder, error) {
@@ -318,8 +504,14 @@ func (p *PaligoClient) showFolder(folderId int) (*Folder, error) {
 	return folder, err
 }
 
-func (p *PaligoClient) showDocument(documentId int) (*Document, error) {
-	body, err := p.request(fmt.Sprintf("documents/%d", documentId), p.documentsLimiter)
+// showDocument returns the document in the given language, or in its source language when language is empty.
+// The language is requested with the language query parameter, the returned document tells its own language.
+func (p *PaligoClient) showDocument(documentId int, language string) (*Document, error) {
+	endpoint := fmt.Sprintf("documents/%d", documentId)
+	if language != "" {
+		endpoint += "?language=" + url.QueryEscape(language)
+	}
+	body, err := p.request(endpoint, p.documentsLimiter)
 	if err != nil {
 		return nil, err
 	}
@@ -330,9 +522,13 @@ func (p *PaligoClient) showDocument(documentId int) (*Document, error) {
 	return document, err
 }
 
-func newPaligoApi(instance string, auth lib.IAuthorizationHeader) *PaligoClient {
+func newPaligoApi(instance string, baseUrl string, auth lib.IAuthorizationHeader) *PaligoClient {
+	if baseUrl == "" {
+		baseUrl = fmt.Sprintf("https://%s.paligoapp.com", instance)
+	}
 	return &PaligoClient{
 		Instance: instance,
+		BaseUrl:  strings.TrimSuffix(baseUrl, "/"),
 		auth:     auth,
 
 		foldersLimiter:   rate.NewLimiter(rateLimitPerSecond(PALIGO_FOLDER_SHOW_LIMIT), PALIGO_FOLDER_SHOW_LIMIT),
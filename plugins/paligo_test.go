@@ -0,0 +1,184 @@
+package plugins
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/checkmarx/2ms/lib"
+	"golang.org/x/time/rate"
+)
+
+func TestPaligoState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "paligo-state.json")
+
+	state, err := loadPaligoState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	document := Component{PaligoItem: PaligoItem{ID: 1}, ModifiedAt: 1000}
+	if state.isUnchanged(document) {
+		t.Errorf("expected document to be scanned without previous state")
+	}
+	state.update(document)
+	state.update(Component{PaligoItem: PaligoItem{ID: 2}})
+	if err := state.save(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	state, err = loadPaligoState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !state.isUnchanged(document) {
+		t.Errorf("expected unchanged document to be skipped")
+	}
+	if state.isUnchanged(Component{PaligoItem: PaligoItem{ID: 1}, ModifiedAt: 2000}) {
+		t.Errorf("expected modified document to be scanned")
+	}
+	if state.isUnchanged(Component{PaligoItem: PaligoItem{ID: 2}}) {
+		t.Errorf("expected document without modification date to be scanned")
+	}
+}
+
+func TestGetPaligoDocumentMetadata(t *testing.T) {
+	item := Component{ReleaseStatus: "released", ModifiedAt: 1700000000, Languages: []string{"en"}}
+
+	metadata := getPaligoDocumentMetadata(item, &Document{Language: "fr", Languages: []string{"en", "fr"}})
+
+	expected := map[string]string{
+		paligoLanguageKey:      "fr",
+		paligoLanguagesKey:     "en, fr",
+		paligoReleaseStatusKey: "released",
+		paligoModifiedAtKey:    "2023-11-14T22:13:20Z",
+	}
+	if len(metadata) != len(expected) {
+		t.Fatalf("expected metadata %v, but got %v", expected, metadata)
+	}
+	for key, value := range expected {
+		if metadata[key] != value {
+			t.Errorf("expected %s %q, but got %q", key, value, metadata[key])
+		}
+	}
+}
+
+func newFakePaligoApi(t *testing.T, requests map[string]int) *httptest.Server {
+	t.Helper()
+
+	mu := sync.Mutex{}
+	// folder 4 and document 14 were deleted, they are skipped
+	// the de translation of document 13 is missing, the en source content is returned instead and skipped
+	responses := map[string]string{
+		"/api/v2/folders?page=1":           `{"page": 1, "next_page": "folders?page=2", "total_pages": 2, "folders": [{"id": 1, "name": "root", "type": "folder", "children": ""}]}`,
+		"/api/v2/folders?page=2":           `{"page": 2, "next_page": "", "total_pages": 2, "folders": [{"id": 3, "name": "other", "type": "folder", "children": ""}]}`,
+		"/api/v2/folders/1":                `{"id": 1, "name": "root", "type": "folder", "children": [{"id": 10, "name": "doc10", "type": "component"}, {"id": 2, "name": "sub", "type": "folder"}, {"id": 4, "name": "deleted", "type": "folder"}]}`,
+		"/api/v2/folders/2":                `{"id": 2, "name": "sub", "type": "folder", "children": [{"id": 11, "name": "doc11", "type": "component"}, {"id": 14, "name": "deleted", "type": "component"}]}`,
+		"/api/v2/folders/3":                `{"id": 3, "name": "other", "type": "folder", "children": [{"id": 13, "name": "doc13", "type": "component"}]}`,
+		"/api/v2/documents/10":             `{"id": 10, "name": "doc10", "type": "document", "content": "password=123"}`,
+		"/api/v2/documents/11":             `{"id": 11, "name": "doc11", "type": "document", "content": "token=abc", "language": "fr", "languages": ["en", "fr"]}`,
+		"/api/v2/documents/11?language=en": `{"id": 11, "name": "doc11", "type": "document", "content": "token=xyz", "language": "en", "languages": ["en", "fr"]}`,
+		"/api/v2/documents/13":             `{"id": 13, "name": "doc13", "type": "document", "content": "key=456", "language": "en", "languages": ["en", "de"]}`,
+		"/api/v2/documents/13?language=de": `{"id": 13, "name": "doc13", "type": "document", "content": "key=789", "language": "en", "languages": ["en", "de"]}`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.RequestURI()]++
+		count := requests[r.URL.RequestURI()]
+		mu.Unlock()
+
+		// the first request of document 10 and all the requests of document 12 are rate limited
+		if (r.URL.Path == "/api/v2/documents/10" && count == 1) || r.URL.Path == "/api/v2/documents/12" {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		response, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+func TestPaligoGetItems(t *testing.T) {
+	requests := map[string]int{}
+	server := newFakePaligoApi(t, requests)
+	defer server.Close()
+
+	paligoInstanceArg = ""
+	paligoBaseUrlArg = server.URL
+	paligoFolderArg = 0
+	p := &PaligoPlugin{
+		Channels:     Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}},
+		translations: true,
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	// documents are fetched concurrently
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	expected := []Item{
+		{Content: "password=123", ID: server.URL + "/document/edit/10"},
+		{Content: "token=abc", ID: server.URL + "/document/edit/11"},
+		{Content: "token=xyz", ID: server.URL + "/document/edit/11; Language: en"},
+		{Content: "key=456", ID: server.URL + "/document/edit/13"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+	}
+	// the source language of document 11 is not the first of its languages
+	if items[1].Metadata[paligoLanguageKey] != "fr" || items[1].Metadata[paligoLanguagesKey] != "en, fr" {
+		t.Errorf("expected document language fr of en, fr, but got %v", items[1].Metadata)
+	}
+	if items[2].Metadata[paligoLanguageKey] != "en" {
+		t.Errorf("expected translation language en, but got %v", items[2].Metadata)
+	}
+	if requests["/api/v2/documents/10"] != 2 {
+		t.Errorf("expected the rate limited document to be requested twice, but got %d requests", requests["/api/v2/documents/10"])
+	}
+	if requests["/api/v2/folders/4"] != 1 || requests["/api/v2/documents/14"] != 1 {
+		t.Errorf("expected the deleted folder and document to be requested once")
+	}
+	if requests["/api/v2/documents/11?language=fr"] != 0 {
+		t.Errorf("expected the source language not to be requested again")
+	}
+}
+
+func TestPaligoRequestRetries(t *testing.T) {
+	requests := map[string]int{}
+	server := newFakePaligoApi(t, requests)
+	defer server.Close()
+
+	client := newPaligoApi("", server.URL, &PaligoPlugin{auth: "auth"})
+	_, err := client.request("documents/12", rate.NewLimiter(rate.Inf, 1))
+	if err == nil {
+		t.Fatalf("expected an error after the retries")
+	}
+	if requests["/api/v2/documents/12"] != lib.MaxHttpRetries+1 {
+		t.Errorf("expected %d requests, but got %d", lib.MaxHttpRetries+1, requests["/api/v2/documents/12"])
+	}
+}
@@ -10,6 +10,11 @@ type Item struct {
 	Content string
 	// Unique identifier of the item (page, document, file) with user-friendly content (e.g. URL, file path)
 	ID string
+	// Additional details about the item (e.g. content type) reported along with its secrets
+	Metadata map[string]string
+	// Original line of each line of the content, when it was converted from another format (e.g. XHTML to text).
+	// Only the lines are mapped, the columns of the secrets found in such content are not reported.
+	LineMapping []int
 }
 
 type Plugin struct {
@@ -1,8 +1,13 @@
 package plugins
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -11,19 +16,36 @@ import (
 )
 
 const (
-	slackTokenFlag            = "token"
-	slackTeamFlag             = "team"
-	slackChannelFlag          = "channel"
-	slackBackwardDurationFlag = "duration"
-	slackMessagesCountFlag    = "messages-count"
+	slackTokenFlag             = "token"
+	slackTeamFlag              = "team"
+	slackAllTeamsFlag          = "all-teams"
+	slackChannelFlag           = "channel"
+	slackBackwardDurationFlag  = "duration"
+	slackMessagesCountFlag     = "messages-count"
+	slackConversationTypesFlag = "conversation-types"
 )
 
+// Conversation types accepted by the flag, mapped to the Slack API types
+var slackConversationTypes = map[string]string{
+	"public":  "public_channel",
+	"private": "private_channel",
+	"im":      "im",
+	"mpim":    "mpim",
+}
+
 const slackDefaultDateFrom = time.Hour * 24 * 14
 
+// Metadata key of the team of the message, useful when scanning several teams of an Enterprise Grid organization
+const slackTeamMetadataKey = "team"
+
 type SlackPlugin struct {
 	Plugin
 	Channels
 	Token string
+
+	// URL of each team, taken from the first message permalink returned by Slack
+	teamsUrls      map[string]string
+	teamsUrlsMutex sync.Mutex
 }
 
 func (p *SlackPlugin) GetName() string {
@@ -31,21 +53,28 @@ func (p *SlackPlugin) GetName() string {
 }
 
 var (
-	tokenArg            string
-	teamArg             string
-	channelsArg         []string
-	backwardDurationArg time.Duration
-	messagesCountArg    int
+	tokenArg             string
+	teamsArg             []string
+	allTeamsArg          bool
+	channelsArg          []string
+	backwardDurationArg  time.Duration
+	messagesCountArg     int
+	conversationTypesArg []string
 )
 
 func (p *SlackPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
 	p.Channels = channels
 
 	command := &cobra.Command{
-		Use:   fmt.Sprintf("%s --%s TOKEN --%s TEAM", p.GetName(), slackTokenFlag, slackTeamFlag),
+		Use:   fmt.Sprintf("%s --%s TOKEN --%s TEAM | --%s", p.GetName(), slackTokenFlag, slackTeamFlag, slackAllTeamsFlag),
 		Short: "Scan Slack team",
-		Long:  "Scan Slack team for sensitive information.",
+		Long:  "Scan Slack team, or all the teams of an Enterprise Grid organization, for sensitive information.",
 		Run: func(cmd *cobra.Command, args []string) {
+			// Waits for MarkFlagsOneRequired https://github.com/spf13/cobra/pull/1952
+			if len(teamsArg) == 0 && !allTeamsArg {
+				p.Errors <- fmt.Errorf("at least one of the flags in the group [%s %s] is required", slackTeamFlag, slackAllTeamsFlag)
+				return
+			}
 			p.getItems()
 		},
 	}
@@ -55,47 +84,54 @@ func (p *SlackPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error while marking flag %s as required: %w", slackTokenFlag, err)
 	}
-	command.Flags().StringVar(&teamArg, slackTeamFlag, "", "Slack team name or ID [required]")
-	err = command.MarkFlagRequired(slackTeamFlag)
-	if err != nil {
-		return nil, fmt.Errorf("error while marking flag %s as required: %w", slackTeamFlag, err)
-	}
+	command.Flags().StringArrayVar(&teamsArg, slackTeamFlag, []string{}, "Slack team names or IDs to scan")
+	command.Flags().BoolVar(&allTeamsArg, slackAllTeamsFlag, false, "Scan all the teams visible to the token (Enterprise Grid organization)")
+	command.MarkFlagsMutuallyExclusive(slackTeamFlag, slackAllTeamsFlag)
 	command.Flags().StringArrayVar(&channelsArg, slackChannelFlag, []string{}, "Slack channels to scan")
 	command.Flags().DurationVar(&backwardDurationArg, slackBackwardDurationFlag, slackDefaultDateFrom, "Slack backward duration for messages (ex: 24h, 7d, 1M, 1y)")
-	command.Flags().IntVar(&messagesCountArg, slackMessagesCountFlag, 0, "Slack messages count to scan (0 = all messages)")
+	command.Flags().IntVar(&messagesCountArg, slackMessagesCountFlag, 0, "Slack messages count to scan, thread replies included (0 = all messages)")
+	command.Flags().StringSliceVar(&conversationTypesArg, slackConversationTypesFlag, []string{"public"}, "Slack conversation types to scan (public, private, im, mpim). The token needs the matching read scopes")
 
 	return command, nil
 }
 
 func (p *SlackPlugin) getItems() {
-	slackApi := slack.New(tokenArg)
+	slackApi := newSlackRateLimitedClient(slack.New(tokenArg), tokenArg)
 
-	team, err := getTeam(slackApi, teamArg)
+	conversationTypes, err := getConversationTypes(conversationTypesArg)
 	if err != nil {
-		p.Errors <- fmt.Errorf("error while getting team: %w", err)
+		p.Errors <- err
 		return
 	}
 
-	channels, err := getChannels(slackApi, team.ID, channelsArg)
+	teams, err := getTeams(slackApi, teamsArg, allTeamsArg)
 	if err != nil {
-		p.Errors <- fmt.Errorf("error while getting channels for team %s: %w", team.Name, err)
-		return
-	}
-	if len(*channels) == 0 {
-		log.Warn().Msgf("No channels found for team %s", team.Name)
+		p.Errors <- fmt.Errorf("error while getting teams: %w", err)
 		return
 	}
 
-	log.Info().Msgf("Found %d channels for team %s", len(*channels), team.Name)
-	p.WaitGroup.Add(len(*channels))
-	for _, channel := range *channels {
-		go p.getItemsFromChannel(slackApi, channel)
+	for _, team := range teams {
+		channels, err := getChannels(slackApi, team.ID, channelsArg, conversationTypes)
+		if err != nil {
+			p.Errors <- fmt.Errorf("error while getting channels for team %s: %w", team.Name, err)
+			return
+		}
+		if len(*channels) == 0 {
+			log.Warn().Msgf("No channels found for team %s", team.Name)
+			continue
+		}
+
+		log.Info().Msgf("Found %d channels for team %s", len(*channels), team.Name)
+		p.WaitGroup.Add(len(*channels))
+		for _, channel := range *channels {
+			go p.getItemsFromChannel(slackApi, team, channel)
+		}
 	}
 }
 
-func (p *SlackPlugin) getItemsFromChannel(slackApi *slack.Client, channel slack.Channel) {
+func (p *SlackPlugin) getItemsFromChannel(slackApi ISlackClient, team slack.Team, channel slack.Channel) {
 	defer p.WaitGroup.Done()
-	log.Info().Msgf("Getting items from channel %s", channel.Name)
+	log.Info().Msgf("Getting items from channel %s", getChannelName(channel))
 
 	cursor := ""
 	counter := 0
@@ -105,7 +141,7 @@ func (p *SlackPlugin) getItemsFromChannel(slackApi *slack.Client, channel slack.
 			ChannelID: channel.ID,
 		})
 		if err != nil {
-			p.Errors <- fmt.Errorf("error while getting history for channel %s: %w", channel.Name, err)
+			p.Errors <- fmt.Errorf("error while getting history for channel %s: %w", getChannelName(channel), err)
 			return
 		}
 		for _, message := range history.Messages {
@@ -117,18 +153,15 @@ func (p *SlackPlugin) getItemsFromChannel(slackApi *slack.Client, channel slack.
 			if outOfRange {
 				break
 			}
-			if message.Text != "" {
-				url, err := slackApi.GetPermalink(&slack.PermalinkParameters{Channel: channel.ID, Ts: message.Timestamp})
+			p.getItemsFromMessage(slackApi, team, channel, message)
+			counter++
+			if message.ReplyCount > 0 {
+				err := p.getItemsFromThread(slackApi, team, channel, message, &counter)
 				if err != nil {
-					log.Warn().Msgf("Error while getting permalink for message %s: %s", message.Timestamp, err)
-					url = fmt.Sprintf("Channel: %s; Message: %s", channel.Name, message.Timestamp)
-				}
-				p.Items <- Item{
-					Content: message.Text,
-					ID:      url,
+					p.Errors <- fmt.Errorf("error while getting replies for message %s in channel %s: %w", message.Timestamp, getChannelName(channel), err)
+					return
 				}
 			}
-			counter++
 		}
 		if history.ResponseMetaData.NextCursor == "" {
 			break
@@ -137,6 +170,181 @@ func (p *SlackPlugin) getItemsFromChannel(slackApi *slack.Client, channel slack.
 	}
 }
 
+// getItemsFromThread scans the replies of a message, they are counted with the channel messages
+func (p *SlackPlugin) getItemsFromThread(slackApi ISlackClient, team slack.Team, channel slack.Channel, parent slack.Message, counter *int) error {
+	cursor := ""
+	for {
+		replies, hasMore, nextCursor, err := slackApi.GetConversationReplies(&slack.GetConversationRepliesParameters{
+			ChannelID: channel.ID,
+			Timestamp: parent.Timestamp,
+			Cursor:    cursor,
+		})
+		if err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			// the parent message is returned with its replies
+			if reply.Timestamp == parent.Timestamp {
+				continue
+			}
+			if messagesCountArg != 0 && *counter >= messagesCountArg {
+				return nil
+			}
+			p.getItemsFromMessage(slackApi, team, channel, reply)
+			*counter++
+		}
+		if !hasMore || nextCursor == "" {
+			return nil
+		}
+		cursor = nextCursor
+	}
+}
+
+func (p *SlackPlugin) getItemsFromMessage(slackApi ISlackClient, team slack.Team, channel slack.Channel, message slack.Message) {
+	content := getMessageContent(message)
+	if content != "" {
+		p.Items <- Item{
+			Content:  content,
+			ID:       p.getMessageUrl(slackApi, team, channel, message),
+			Metadata: map[string]string{slackTeamMetadataKey: team.Name},
+		}
+	}
+
+	for _, file := range message.Files {
+		item, err := getFileItem(slackApi, file)
+		if err != nil {
+			log.Warn().Msgf("Skipping file %s of message %s: %s", file.Name, message.Timestamp, err)
+			continue
+		}
+		item.Metadata = map[string]string{slackTeamMetadataKey: team.Name}
+		p.Items <- *item
+	}
+}
+
+// getMessageUrl returns the message permalink. The first permalink of a team is requested, the next ones are built
+// locally with the team URL it contains, since Enterprise Grid workspaces are not all on <domain>.slack.com
+func (p *SlackPlugin) getMessageUrl(slackApi ISlackClient, team slack.Team, channel slack.Channel, message slack.Message) string {
+	p.teamsUrlsMutex.Lock()
+	teamUrl := p.teamsUrls[team.ID]
+	p.teamsUrlsMutex.Unlock()
+	if teamUrl != "" {
+		return getMessagePermalink(teamUrl, channel, message)
+	}
+
+	permalink, err := slackApi.GetPermalink(&slack.PermalinkParameters{Channel: channel.ID, Ts: message.Timestamp})
+	if err != nil {
+		log.Warn().Msgf("Error while getting permalink for message %s: %s", message.Timestamp, err)
+		return getMessagePermalink("", channel, message)
+	}
+
+	if parsed, err := url.Parse(permalink); err == nil && strings.HasPrefix(parsed.Path, "/archives/") {
+		p.teamsUrlsMutex.Lock()
+		if p.teamsUrls == nil {
+			p.teamsUrls = map[string]string{}
+		}
+		p.teamsUrls[team.ID] = fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host)
+		p.teamsUrlsMutex.Unlock()
+	}
+	return permalink
+}
+
+// getMessagePermalink builds the message permalink locally from the team URL, instead of calling chat.getPermalink
+func getMessagePermalink(teamUrl string, channel slack.Channel, message slack.Message) string {
+	if teamUrl == "" {
+		return fmt.Sprintf("Channel: %s; Message: %s", getChannelName(channel), message.Timestamp)
+	}
+
+	permalink := fmt.Sprintf("%s/archives/%s/p%s", teamUrl, channel.ID, strings.Replace(message.Timestamp, ".", "", 1))
+	if message.ThreadTimestamp != "" && message.ThreadTimestamp != message.Timestamp {
+		permalink += fmt.Sprintf("?thread_ts=%s&cid=%s", message.ThreadTimestamp, channel.ID)
+	}
+	return permalink
+}
+
+// getMessageContent returns the text of a message with the text of its attachments and blocks
+func getMessageContent(message slack.Message) string {
+	texts := []string{message.Text}
+
+	for _, attachment := range message.Attachments {
+		texts = append(texts, attachment.Pretext, attachment.Title, attachment.TitleLink, attachment.Text)
+		for _, field := range attachment.Fields {
+			texts = append(texts, field.Value)
+		}
+		if attachment.Text == "" {
+			texts = append(texts, attachment.Fallback)
+		}
+	}
+
+	for _, block := range message.Blocks.BlockSet {
+		switch block := block.(type) {
+		case *slack.SectionBlock:
+			texts = append(texts, getTextBlockObjectText(block.Text))
+			for _, field := range block.Fields {
+				texts = append(texts, getTextBlockObjectText(field))
+			}
+		case *slack.HeaderBlock:
+			texts = append(texts, getTextBlockObjectText(block.Text))
+		case *slack.ContextBlock:
+			for _, element := range block.ContextElements.Elements {
+				if text, ok := element.(*slack.TextBlockObject); ok {
+					texts = append(texts, getTextBlockObjectText(text))
+				}
+			}
+		}
+	}
+
+	contents := []string{}
+	seen := map[string]bool{}
+	for _, text := range texts {
+		if text != "" && !seen[text] {
+			seen[text] = true
+			contents = append(contents, text)
+		}
+	}
+	return strings.Join(contents, "\n")
+}
+
+func getTextBlockObjectText(text *slack.TextBlockObject) string {
+	if text == nil {
+		return ""
+	}
+	return text.Text
+}
+
+// getFileItem downloads a text-like file or snippet shared in a message
+func getFileItem(slackApi ISlackClient, file slack.File) (*Item, error) {
+	if file.Size > maxAttachmentSize {
+		return nil, fmt.Errorf("file size %d exceeds the limit of %d bytes", file.Size, maxAttachmentSize)
+	}
+
+	url := file.URLPrivateDownload
+	if url == "" {
+		url = file.URLPrivate
+	}
+	if url == "" {
+		return nil, fmt.Errorf("file has no download url")
+	}
+
+	buffer := &bytes.Buffer{}
+	if err := slackApi.GetFile(url, buffer); err != nil {
+		return nil, fmt.Errorf("error while downloading file: %w", err)
+	}
+
+	content, err := getAttachmentContent(file.Name, file.Mimetype, buffer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	id := file.Permalink
+	if id == "" {
+		id = url
+	}
+	return &Item{
+		Content: content,
+		ID:      id,
+	}, nil
+}
+
 // Declare it to be consistent with all comparaisons
 var timeNow = time.Now()
 
@@ -160,9 +368,16 @@ func isMessageOutOfRange(message slack.Message, backwardDuration time.Duration,
 type ISlackClient interface {
 	GetConversations(*slack.GetConversationsParameters) ([]slack.Channel, string, error)
 	ListTeams(slack.ListTeamsParameters) ([]slack.Team, string, error)
+	GetPermalink(*slack.PermalinkParameters) (string, error)
+	GetConversationHistory(*slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
+	GetConversationReplies(*slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
+	GetFile(string, io.Writer) error
 }
 
-func getTeam(slackApi ISlackClient, teamName string) (*slack.Team, error) {
+// getTeams returns the wanted teams, or all the teams visible to the token
+func getTeams(slackApi ISlackClient, wantedTeams []string, allTeams bool) ([]slack.Team, error) {
+	selectedTeams := []slack.Team{}
+	found := map[string]bool{}
 	cursorHolder := ""
 	for {
 		teams, cursor, err := slackApi.ListTeams(slack.ListTeamsParameters{Cursor: cursorHolder})
@@ -170,8 +385,16 @@ func getTeam(slackApi ISlackClient, teamName string) (*slack.Team, error) {
 			return nil, fmt.Errorf("error while getting teams: %w", err)
 		}
 		for _, team := range teams {
-			if team.Name == teamName || team.ID == teamName {
-				return &team, nil
+			if allTeams {
+				selectedTeams = append(selectedTeams, team)
+				continue
+			}
+			for _, wanted := range wantedTeams {
+				if !found[wanted] && (team.Name == wanted || team.ID == wanted) {
+					found[wanted] = true
+					selectedTeams = append(selectedTeams, team)
+					break
+				}
 			}
 		}
 		if cursor == "" {
@@ -179,16 +402,46 @@ func getTeam(slackApi ISlackClient, teamName string) (*slack.Team, error) {
 		}
 		cursorHolder = cursor
 	}
-	return nil, fmt.Errorf("team '%s' not found", teamName)
+
+	for _, wanted := range wantedTeams {
+		if !found[wanted] {
+			return nil, fmt.Errorf("team '%s' not found", wanted)
+		}
+	}
+	return selectedTeams, nil
+}
+
+func getConversationTypes(types []string) ([]string, error) {
+	conversationTypes := []string{}
+	for _, conversationType := range types {
+		slackType, ok := slackConversationTypes[strings.ToLower(conversationType)]
+		if !ok {
+			return nil, fmt.Errorf("invalid conversation type: %s, available types are: public, private, im and mpim", conversationType)
+		}
+		conversationTypes = append(conversationTypes, slackType)
+	}
+	return conversationTypes, nil
+}
+
+// getChannelName returns a readable name of the conversation, direct messages have no name
+func getChannelName(channel slack.Channel) string {
+	if channel.Name != "" {
+		return channel.Name
+	}
+	if channel.IsIM && channel.User != "" {
+		return fmt.Sprintf("DM with %s", channel.User)
+	}
+	return channel.ID
 }
 
-func getChannels(slackApi ISlackClient, teamId string, wantedChannels []string) (*[]slack.Channel, error) {
+func getChannels(slackApi ISlackClient, teamId string, wantedChannels []string, conversationTypes []string) (*[]slack.Channel, error) {
 	cursorHolder := ""
 	selectedChannels := []slack.Channel{}
 	for {
 		channels, cursor, err := slackApi.GetConversations(&slack.GetConversationsParameters{
 			Cursor: cursorHolder,
 			TeamID: teamId,
+			Types:  conversationTypes,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("error while getting channels: %w", err)
@@ -198,7 +451,7 @@ func getChannels(slackApi ISlackClient, teamId string, wantedChannels []string)
 		} else {
 			for _, channel := range wantedChannels {
 				for _, c := range channels {
-					if c.Name == channel || c.ID == channel {
+					if c.Name == channel || c.ID == channel || (c.IsIM && c.User == channel) {
 						selectedChannels = append(selectedChannels, c)
 					}
 				}
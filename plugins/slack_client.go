@@ -0,0 +1,138 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"golang.org/x/time/rate"
+)
+
+// https://api.slack.com/docs/rate-limits
+const (
+	SLACK_TIER_2_RATE_LIMIT = 20
+	SLACK_TIER_3_RATE_LIMIT = 50
+	SLACK_TIER_4_RATE_LIMIT = 100
+)
+
+// Requests per minute allowed for each Slack Web API method used by the plugin, the files downloads
+// are not a Web API method and are paced like the tier 4 methods
+var slackMethodsRateLimits = map[string]int{
+	"auth.teams.list":       SLACK_TIER_2_RATE_LIMIT,
+	"conversations.list":    SLACK_TIER_2_RATE_LIMIT,
+	"conversations.history": SLACK_TIER_3_RATE_LIMIT,
+	"conversations.replies": SLACK_TIER_3_RATE_LIMIT,
+	"chat.getPermalink":     SLACK_TIER_4_RATE_LIMIT,
+	"files.download":        SLACK_TIER_4_RATE_LIMIT,
+}
+
+// slackRateLimitedClient paces the calls to the Slack Web API according to the method tier,
+// and retries the calls rejected by Slack after the Retry-After delay
+type slackRateLimitedClient struct {
+	client   ISlackClient
+	token    string
+	limiters map[string]*rate.Limiter
+}
+
+func newSlackRateLimitedClient(client ISlackClient, token string) *slackRateLimitedClient {
+	limiters := make(map[string]*rate.Limiter)
+	for method, rateLimit := range slackMethodsRateLimits {
+		limiters[method] = rate.NewLimiter(rateLimitPerSecond(rateLimit), 1)
+	}
+	return &slackRateLimitedClient{
+		client:   client,
+		token:    token,
+		limiters: limiters,
+	}
+}
+
+func (c *slackRateLimitedClient) GetAuthorizationHeader() (string, error) {
+	return lib.CreateBearerAuthCredentials(c.token), nil
+}
+
+func (c *slackRateLimitedClient) wait(method string) error {
+	if limiter, ok := c.limiters[method]; ok {
+		return limiter.Wait(context.Background())
+	}
+	return nil
+}
+
+// call retries the Web API calls like lib.RetryHttpRequest does, the Slack client returns the rejected calls
+// as a RateLimitedError instead of their response
+func (c *slackRateLimitedClient) call(method string, request func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := c.wait(method); err != nil {
+			return err
+		}
+
+		err := request()
+		var rateLimitedError *slack.RateLimitedError
+		if !errors.As(err, &rateLimitedError) || attempt >= lib.MaxHttpRetries {
+			return err
+		}
+
+		log.Warn().Msgf("Slack rate limit exceeded for %s, retrying in %s", method, rateLimitedError.RetryAfter)
+		time.Sleep(rateLimitedError.RetryAfter)
+	}
+}
+
+func (c *slackRateLimitedClient) GetConversations(params *slack.GetConversationsParameters) (channels []slack.Channel, cursor string, err error) {
+	err = c.call("conversations.list", func() error {
+		channels, cursor, err = c.client.GetConversations(params)
+		return err
+	})
+	return channels, cursor, err
+}
+
+func (c *slackRateLimitedClient) ListTeams(params slack.ListTeamsParameters) (teams []slack.Team, cursor string, err error) {
+	err = c.call("auth.teams.list", func() error {
+		teams, cursor, err = c.client.ListTeams(params)
+		return err
+	})
+	return teams, cursor, err
+}
+
+func (c *slackRateLimitedClient) GetPermalink(params *slack.PermalinkParameters) (permalink string, err error) {
+	err = c.call("chat.getPermalink", func() error {
+		permalink, err = c.client.GetPermalink(params)
+		return err
+	})
+	return permalink, err
+}
+
+func (c *slackRateLimitedClient) GetConversationHistory(params *slack.GetConversationHistoryParameters) (history *slack.GetConversationHistoryResponse, err error) {
+	err = c.call("conversations.history", func() error {
+		history, err = c.client.GetConversationHistory(params)
+		return err
+	})
+	return history, err
+}
+
+func (c *slackRateLimitedClient) GetConversationReplies(params *slack.GetConversationRepliesParameters) (replies []slack.Message, hasMore bool, cursor string, err error) {
+	err = c.call("conversations.replies", func() error {
+		replies, hasMore, cursor, err = c.client.GetConversationReplies(params)
+		return err
+	})
+	return replies, hasMore, cursor, err
+}
+
+// GetFile downloads the file with lib.RetryHttpRequest, the Slack client does not return a RateLimitedError
+// for a 429 Too Many Requests without a Retry-After header, so its downloads would not be retried
+func (c *slackRateLimitedClient) GetFile(downloadURL string, writer io.Writer) error {
+	body, _, err := lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		if err := c.wait("files.download"); err != nil {
+			return nil, nil, err
+		}
+		return lib.HttpRequest(http.MethodGet, downloadURL, c)
+	}, lib.RetryAfterDelay)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(body)
+	return err
+}
@@ -0,0 +1,213 @@
+package plugins
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/slack-go/slack"
+	"github.com/spf13/cobra"
+)
+
+const (
+	slackExportDomainFlag  = "domain"
+	slackExportChannelFlag = "channel"
+)
+
+// Files of a Slack export listing the conversations, each conversation messages are in a folder named after it
+var slackExportConversationsFiles = []string{"channels.json", "groups.json", "mpims.json", "dms.json"}
+
+const slackExportUsersFile = "users.json"
+
+type SlackExportPlugin struct {
+	Plugin
+	Channels
+
+	domain   string
+	channels []string
+}
+
+type slackExportUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+}
+
+func (p *SlackExportPlugin) GetName() string {
+	return "slack-export"
+}
+
+func (p *SlackExportPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s <ZIP>", p.GetName()),
+		Short: "Scan Slack workspace export",
+		Long:  "Scan Slack workspace export archive for sensitive information, without access to the Slack API.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			log.Info().Msg("Slack export plugin started")
+			if err := p.scanExport(args[0]); err != nil {
+				p.Errors <- err
+			}
+		},
+	}
+
+	command.Flags().StringVar(&p.domain, slackExportDomainFlag, "", "Slack workspace domain (<domain>.slack.com), used to build messages permalinks")
+	command.Flags().StringArrayVar(&p.channels, slackExportChannelFlag, []string{}, "Slack channels to scan. If not provided, all the exported conversations will be scanned")
+
+	return command, nil
+}
+
+func (p *SlackExportPlugin) scanExport(zipPath string) error {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("error while opening slack export %s: %w", zipPath, err)
+	}
+	defer reader.Close()
+
+	exportUsers := []slackExportUser{}
+	if err := readSlackExportFile(&reader.Reader, slackExportUsersFile, &exportUsers); err != nil {
+		return err
+	}
+	users := map[string]slackExportUser{}
+	for _, user := range exportUsers {
+		users[user.ID] = user
+	}
+
+	conversations := map[string]slack.Channel{}
+	for _, fileName := range slackExportConversationsFiles {
+		exportChannels := []slack.Channel{}
+		if err := readSlackExportFile(&reader.Reader, fileName, &exportChannels); err != nil {
+			return err
+		}
+		for _, channel := range exportChannels {
+			folder := channel.Name
+			// direct messages have no name, their folder is named after their ID
+			if folder == "" {
+				folder = channel.ID
+				channel.IsIM = true
+				channel.User = getSlackExportMembersNames(channel, users)
+			}
+			conversations[folder] = channel
+		}
+	}
+	log.Info().Msgf("Found %d conversations and %d users in slack export", len(conversations), len(users))
+
+	teamUrl := ""
+	if p.domain != "" {
+		teamUrl = fmt.Sprintf("https://%s.slack.com", p.domain)
+	}
+	for _, file := range reader.File {
+		folder, fileName := path.Split(file.Name)
+		folder = strings.TrimSuffix(folder, "/")
+		if folder == "" || strings.Contains(folder, "/") || path.Ext(fileName) != ".json" {
+			continue
+		}
+
+		channel, ok := conversations[folder]
+		if !ok {
+			channel = slack.Channel{GroupConversation: slack.GroupConversation{Name: folder, Conversation: slack.Conversation{ID: folder}}}
+		}
+		if !p.isSelectedChannel(channel) {
+			continue
+		}
+
+		messages := []slack.Message{}
+		if err := readSlackExportEntry(file, &messages); err != nil {
+			return fmt.Errorf("error while reading %s: %w", file.Name, err)
+		}
+		log.Debug().Msgf("Found %d messages in %s", len(messages), file.Name)
+
+		for _, message := range messages {
+			p.sendMessageItems(teamUrl, channel, message)
+		}
+	}
+
+	return nil
+}
+
+func (p *SlackExportPlugin) isSelectedChannel(channel slack.Channel) bool {
+	if len(p.channels) == 0 {
+		return true
+	}
+	for _, wanted := range p.channels {
+		if channel.Name == wanted || channel.ID == wanted {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SlackExportPlugin) sendMessageItems(teamUrl string, channel slack.Channel, message slack.Message) {
+	permalink := getMessagePermalink(teamUrl, channel, message)
+
+	content := getMessageContent(message)
+	if content != "" {
+		p.Items <- Item{
+			Content: content,
+			ID:      permalink,
+		}
+	}
+
+	// Files are not part of the export, only their metadata and the preview of snippets can be scanned
+	for _, file := range message.Files {
+		content := strings.Join([]string{file.Title, file.Name, file.Preview}, "\n")
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		id := file.Permalink
+		if id == "" {
+			id = fmt.Sprintf("%s; File: %s", permalink, file.Name)
+		}
+		p.Items <- Item{
+			Content: content,
+			ID:      id,
+		}
+	}
+}
+
+func getSlackExportMembersNames(channel slack.Channel, users map[string]slackExportUser) string {
+	names := []string{}
+	for _, member := range channel.Members {
+		if user, ok := users[member]; ok {
+			names = append(names, user.Name)
+		} else {
+			names = append(names, member)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// readSlackExportFile decodes an optional file at the root of the export
+func readSlackExportFile(reader *zip.Reader, fileName string, v interface{}) error {
+	for _, file := range reader.File {
+		if file.Name != fileName {
+			continue
+		}
+		if err := readSlackExportEntry(file, v); err != nil {
+			return fmt.Errorf("error while reading %s: %w", fileName, err)
+		}
+		return nil
+	}
+	log.Debug().Msgf("%s not found in slack export", fileName)
+	return nil
+}
+
+func readSlackExportEntry(file *zip.File, v interface{}) error {
+	entry, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer entry.Close()
+
+	content, err := io.ReadAll(entry)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
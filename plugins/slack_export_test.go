@@ -0,0 +1,62 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSlackExportScanExport(t *testing.T) {
+	export := createZip(t, map[string]string{
+		"users.json":    `[{"id": "U1", "name": "alice"}, {"id": "U2", "name": "bob"}]`,
+		"channels.json": `[{"id": "C1", "name": "general"}, {"id": "C2", "name": "random"}]`,
+		"dms.json":      `[{"id": "D1", "members": ["U1", "U2"]}]`,
+		"general/2023-05-01.json": `[
+			{"type": "message", "user": "U1", "text": "the password is 123", "ts": "1682899200.000100", "thread_ts": "1682899200.000100", "reply_count": 1},
+			{"type": "message", "user": "U2", "text": "thanks", "ts": "1682899300.000200", "thread_ts": "1682899200.000100"},
+			{"type": "message", "user": "U2", "text": "", "ts": "1682899400.000300", "files": [{"name": "prod.env", "title": "prod env", "preview": "TOKEN=abc", "permalink": "https://team.slack.com/files/U2/F1/prod.env"}]}
+		]`,
+		"random/2023-05-01.json": `[{"type": "message", "user": "U1", "text": "not selected", "ts": "1682899200.000100"}]`,
+		"D1/2023-05-02.json":     `[{"type": "message", "user": "U1", "text": "direct message", "ts": "1682985600.000100"}]`,
+	})
+	zipPath := filepath.Join(t.TempDir(), "export.zip")
+	if err := os.WriteFile(zipPath, export, 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p := &SlackExportPlugin{
+		Channels: Channels{Items: make(chan Item), Errors: make(chan error), WaitGroup: &sync.WaitGroup{}},
+		domain:   "team",
+		channels: []string{"general", "D1"},
+	}
+
+	var err error
+	go func() {
+		err = p.scanExport(zipPath)
+		close(p.Items)
+	}()
+
+	items := map[string]string{}
+	for item := range p.Items {
+		items[item.ID] = item.Content
+	}
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"https://team.slack.com/archives/C1/p1682899200000100":                                    "the password is 123",
+		"https://team.slack.com/archives/C1/p1682899300000200?thread_ts=1682899200.000100&cid=C1": "thanks",
+		"https://team.slack.com/files/U2/F1/prod.env":                                             "prod env\nprod.env\nTOKEN=abc",
+		"https://team.slack.com/archives/D1/p1682985600000100":                                    "direct message",
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for id, content := range expected {
+		if items[id] != content {
+			t.Errorf("expected item %s with content %q, but got %q", id, content, items[id])
+		}
+	}
+}
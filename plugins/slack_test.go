@@ -3,15 +3,26 @@ package plugins
 import (
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/checkmarx/2ms/lib"
 	"github.com/slack-go/slack"
+	"golang.org/x/time/rate"
 )
 
 type mockSlackClient struct {
+	teams    []slack.Team
 	channels []slack.Channel
+	messages []slack.Message
+	replies  map[string][]slack.Message
+	files    map[string]string
 	err      error
 }
 
@@ -19,7 +30,27 @@ func (m *mockSlackClient) GetConversations(params *slack.GetConversationsParamet
 	return m.channels, "", m.err
 }
 func (m *mockSlackClient) ListTeams(params slack.ListTeamsParameters) ([]slack.Team, string, error) {
-	return nil, "", errors.New("not implemented")
+	if m.teams == nil {
+		return nil, "", errors.New("not implemented")
+	}
+	return m.teams, "", m.err
+}
+func (m *mockSlackClient) GetPermalink(params *slack.PermalinkParameters) (string, error) {
+	return fmt.Sprintf("https://team.slack.com/archives/%s/p%s", params.Channel, strings.Replace(params.Ts, ".", "", 1)), m.err
+}
+func (m *mockSlackClient) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	return &slack.GetConversationHistoryResponse{Messages: m.messages}, m.err
+}
+func (m *mockSlackClient) GetConversationReplies(params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	return m.replies[params.Timestamp], false, "", m.err
+}
+func (m *mockSlackClient) GetFile(downloadURL string, writer io.Writer) error {
+	content, ok := m.files[downloadURL]
+	if !ok {
+		return errors.New("file not found")
+	}
+	_, err := writer.Write([]byte(content))
+	return err
 }
 
 func TestGetChannels(t *testing.T) {
@@ -92,7 +123,7 @@ func TestGetChannels(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := getChannels(&tt.slackApi, tt.teamId, tt.wantedChannels)
+			result, err := getChannels(&tt.slackApi, tt.teamId, tt.wantedChannels, []string{"public_channel"})
 			if err != nil && tt.expectedError == nil {
 				t.Errorf("unexpected error: %v", err)
 			}
@@ -182,3 +213,311 @@ func TestIsMessageOutOfRange(t *testing.T) {
 		})
 	}
 }
+
+func TestGetItemsFromChannel(t *testing.T) {
+	now := formatSecondsAnd6DigitsMiliseconds(timeNow)
+	reply := formatSecondsAnd6DigitsMiliseconds(timeNow.Add(time.Second))
+	slackApi := &mockSlackClient{
+		messages: []slack.Message{
+			{Msg: slack.Msg{Timestamp: now, Text: "parent message", ReplyCount: 1, ThreadTimestamp: now}},
+		},
+		replies: map[string][]slack.Message{
+			now: {
+				{Msg: slack.Msg{Timestamp: now, Text: "parent message", ThreadTimestamp: now}},
+				{Msg: slack.Msg{Timestamp: reply, Text: "reply message", ThreadTimestamp: now, Files: []slack.File{
+					{Name: "prod.env", URLPrivateDownload: "https://files.slack.com/prod.env", Permalink: "https://team.slack.com/files/prod.env"},
+					{Name: "image.png", URLPrivateDownload: "https://files.slack.com/image.png"},
+				}}},
+			},
+		},
+		files: map[string]string{
+			"https://files.slack.com/prod.env": "PASSWORD=123",
+		},
+	}
+
+	backwardDurationArg = time.Hour
+	messagesCountArg = 0
+
+	// the first permalink is requested, the next ones are built with its team URL
+	items := scanSlackChannel(slackApi, slack.Team{ID: "T123456", Name: "team name"})
+
+	expected := []Item{
+		{Content: "parent message", ID: fmt.Sprintf("https://team.slack.com/archives/C123456/p%s", strings.ReplaceAll(now, ".", ""))},
+		{Content: "reply message", ID: fmt.Sprintf("https://team.slack.com/archives/C123456/p%s?thread_ts=%s&cid=C123456", strings.ReplaceAll(reply, ".", ""), now)},
+		{Content: "PASSWORD=123", ID: "https://team.slack.com/files/prod.env"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+		if item.Metadata[slackTeamMetadataKey] != "team name" {
+			t.Errorf("expected team metadata %q, but got %v", "team name", item.Metadata)
+		}
+	}
+}
+
+func TestGetItemsFromChannelMessagesCount(t *testing.T) {
+	first := formatSecondsAnd6DigitsMiliseconds(timeNow)
+	second := formatSecondsAnd6DigitsMiliseconds(timeNow.Add(-time.Second))
+	reply := formatSecondsAnd6DigitsMiliseconds(timeNow.Add(time.Second))
+	slackApi := &mockSlackClient{
+		messages: []slack.Message{
+			{Msg: slack.Msg{Timestamp: first, Text: "first message", ReplyCount: 2, ThreadTimestamp: first}},
+			{Msg: slack.Msg{Timestamp: second, Text: "second message"}},
+		},
+		replies: map[string][]slack.Message{
+			first: {
+				{Msg: slack.Msg{Timestamp: first, Text: "first message", ThreadTimestamp: first}},
+				{Msg: slack.Msg{Timestamp: reply, Text: "first reply", ThreadTimestamp: first}},
+				{Msg: slack.Msg{Timestamp: reply + "1", Text: "second reply", ThreadTimestamp: first}},
+			},
+		},
+	}
+
+	backwardDurationArg = time.Hour
+	messagesCountArg = 2
+	defer func() { messagesCountArg = 0 }()
+
+	items := scanSlackChannel(slackApi, slack.Team{ID: "T123456", Name: "team name"})
+
+	expected := []string{"first message", "first reply"}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i] {
+			t.Errorf("expected item %q, but got %q", expected[i], item.Content)
+		}
+	}
+}
+
+// scanSlackChannel returns the items of the channel C123456 of the given team
+func scanSlackChannel(slackApi ISlackClient, team slack.Team) []Item {
+	p := &SlackPlugin{Channels: Channels{Items: make(chan Item), Errors: make(chan error), WaitGroup: &sync.WaitGroup{}}}
+
+	p.WaitGroup.Add(1)
+	go func() {
+		p.getItemsFromChannel(slackApi, team, slack.Channel{GroupConversation: slack.GroupConversation{Name: "channel1", Conversation: slack.Conversation{ID: "C123456"}}})
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestGetTeams(t *testing.T) {
+	slackApi := &mockSlackClient{
+		teams: []slack.Team{
+			{ID: "T123456", Name: "team1"},
+			{ID: "T234567", Name: "team2"},
+			{ID: "T345678", Name: "team3"},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		wantedTeams   []string
+		allTeams      bool
+		expectedTeams []string
+		expectedError error
+	}{
+		{
+			name:          "all teams",
+			allTeams:      true,
+			expectedTeams: []string{"T123456", "T234567", "T345678"},
+		},
+		{
+			name:          "teams by name and ID",
+			wantedTeams:   []string{"team1", "T345678"},
+			expectedTeams: []string{"T123456", "T345678"},
+		},
+		{
+			name:          "team not found",
+			wantedTeams:   []string{"team1", "team4"},
+			expectedError: errors.New("team 'team4' not found"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			teams, err := getTeams(slackApi, tt.wantedTeams, tt.allTeams)
+			if tt.expectedError != nil {
+				if err == nil || err.Error() != tt.expectedError.Error() {
+					t.Fatalf("expected error %v, but got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(teams) != len(tt.expectedTeams) {
+				t.Fatalf("expected %d teams, but got %d", len(tt.expectedTeams), len(teams))
+			}
+			for i, team := range teams {
+				if team.ID != tt.expectedTeams[i] {
+					t.Errorf("expected team %s, but got %s", tt.expectedTeams[i], team.ID)
+				}
+			}
+		})
+	}
+}
+
+// gridSlackClient returns the permalinks of an Enterprise Grid workspace
+type gridSlackClient struct {
+	mockSlackClient
+	permalinkRequests int
+}
+
+func (m *gridSlackClient) GetPermalink(params *slack.PermalinkParameters) (string, error) {
+	m.permalinkRequests++
+	return fmt.Sprintf("https://acme-eng.enterprise.slack.com/archives/%s/p%s", params.Channel, strings.Replace(params.Ts, ".", "", 1)), nil
+}
+
+func TestGetMessageUrl(t *testing.T) {
+	slackApi := &gridSlackClient{}
+	p := &SlackPlugin{}
+	team := slack.Team{ID: "T123456", Name: "engineering", Domain: "acme-eng"}
+	channel := slack.Channel{GroupConversation: slack.GroupConversation{Conversation: slack.Conversation{ID: "C123456"}}}
+
+	requested := p.getMessageUrl(slackApi, team, channel, slack.Message{Msg: slack.Msg{Timestamp: "1690000000.000100"}})
+	if expected := "https://acme-eng.enterprise.slack.com/archives/C123456/p1690000000000100"; requested != expected {
+		t.Errorf("expected requested permalink %q, but got %q", expected, requested)
+	}
+
+	reply := slack.Message{Msg: slack.Msg{Timestamp: "1700000000.000100", ThreadTimestamp: "1690000000.000100"}}
+	local := p.getMessageUrl(slackApi, team, channel, reply)
+	if expected := "https://acme-eng.enterprise.slack.com/archives/C123456/p1700000000000100?thread_ts=1690000000.000100&cid=C123456"; local != expected {
+		t.Errorf("expected local permalink %q, but got %q", expected, local)
+	}
+	if slackApi.permalinkRequests != 1 {
+		t.Errorf("expected one permalink request, but got %d", slackApi.permalinkRequests)
+	}
+}
+
+func TestGetMessageContent(t *testing.T) {
+	message := slack.Message{Msg: slack.Msg{
+		Text: "deploy keys",
+		Attachments: []slack.Attachment{
+			{Pretext: "deploy keys", Text: "key: 123", Fields: []slack.AttachmentField{{Title: "secret", Value: "abc"}}},
+		},
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, "deploy keys", false, false), []*slack.TextBlockObject{
+				slack.NewTextBlockObject(slack.PlainTextType, "token: xyz", false, false),
+			}, nil),
+		}},
+	}}
+
+	content := getMessageContent(message)
+
+	expected := "deploy keys\nkey: 123\nabc\ntoken: xyz"
+	if content != expected {
+		t.Errorf("expected content %q, but got %q", expected, content)
+	}
+}
+
+func TestGetConversationTypes(t *testing.T) {
+	types, err := getConversationTypes([]string{"public", "Private", "im", "mpim"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"public_channel", "private_channel", "im", "mpim"}
+	if strings.Join(types, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected types %v, but got %v", expected, types)
+	}
+
+	if _, err := getConversationTypes([]string{"group"}); err == nil {
+		t.Errorf("expected error for invalid conversation type, but got nil")
+	}
+}
+
+type rateLimitedSlackClient struct {
+	mockSlackClient
+	failures int
+	calls    int
+}
+
+func (m *rateLimitedSlackClient) GetConversationHistory(params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return nil, &slack.RateLimitedError{RetryAfter: time.Millisecond}
+	}
+	return m.mockSlackClient.GetConversationHistory(params)
+}
+
+func TestSlackRateLimitedClientGetFileRetry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("unexpected authorization header %q", r.Header.Get("Authorization"))
+		}
+		// rate limited without a Retry-After header
+		if requests == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("PASSWORD="))
+			return
+		}
+		_, _ = w.Write([]byte("PASSWORD=123"))
+	}))
+	defer server.Close()
+
+	client := newSlackRateLimitedClient(&mockSlackClient{}, "token")
+	client.limiters = map[string]*rate.Limiter{}
+
+	buffer := &strings.Builder{}
+	if err := client.GetFile(server.URL+"/prod.env", buffer); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buffer.String() != "PASSWORD=123" {
+		t.Errorf("expected the content of the last attempt, but got %q", buffer.String())
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 requests, but got %d", requests)
+	}
+}
+
+func TestSlackRateLimitedClientRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		expectedCalls int
+		expectedError bool
+	}{
+		{
+			name:          "retries until success",
+			failures:      2,
+			expectedCalls: 3,
+		},
+		{
+			name:          "gives up after max retries",
+			failures:      lib.MaxHttpRetries + 10,
+			expectedCalls: lib.MaxHttpRetries + 1,
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &rateLimitedSlackClient{failures: tt.failures}
+			client := newSlackRateLimitedClient(mock, "token")
+			client.limiters = map[string]*rate.Limiter{}
+
+			_, err := client.GetConversationHistory(&slack.GetConversationHistoryParameters{ChannelID: "C123456"})
+			if tt.expectedError && err == nil {
+				t.Errorf("expected error, but got nil")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if mock.calls != tt.expectedCalls {
+				t.Errorf("expected %d calls, but got %d", tt.expectedCalls, mock.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package plugins
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	stdinSplitFlag        = "split"
+	stdinIdFlag           = "id"
+	stdinIdFieldFlag      = "id-field"
+	stdinContentFieldFlag = "content-field"
+)
+
+const (
+	stdinSplitNone   = "none"
+	stdinSplitNul    = "nul"
+	stdinSplitNdjson = "ndjson"
+)
+
+const stdinDefaultId = "stdin"
+
+type StdinPlugin struct {
+	Plugin
+	Channels
+
+	Split        string
+	DefaultID    string
+	IdField      string
+	ContentField string
+	// Input is read instead of the standard input when set
+	Input io.Reader
+}
+
+func (p *StdinPlugin) GetName() string {
+	return "stdin"
+}
+
+func (p *StdinPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   p.GetName(),
+		Short: "Scan standard input",
+		Long:  "Scan the text piped to the standard input for sensitive information, as a single item or split in records",
+		Example: fmt.Sprintf("  kubectl get secrets -o yaml | 2ms %s\n  git log -z -p | 2ms %s --%s %s\n  jq -c '{id: .url, content: .body}' pages.json | 2ms %s --%s %s",
+			p.GetName(), p.GetName(), stdinSplitFlag, stdinSplitNul, p.GetName(), stdinSplitFlag, stdinSplitNdjson),
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.initialize(); err != nil {
+				p.Errors <- fmt.Errorf("error while initializing stdin plugin: %w", err)
+				return
+			}
+			log.Info().Msg("Stdin plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.Split, stdinSplitFlag, stdinSplitNone, fmt.Sprintf("Split the input in records: %s (single item), %s (NUL separated records) or %s (newline delimited JSON records)", stdinSplitNone, stdinSplitNul, stdinSplitNdjson))
+	flags.StringVar(&p.DefaultID, stdinIdFlag, stdinDefaultId, "ID of the input, the records IDs are suffixed with their position")
+	flags.StringVar(&p.IdField, stdinIdFieldFlag, "id", fmt.Sprintf("Field of the %s records holding their ID", stdinSplitNdjson))
+	flags.StringVar(&p.ContentField, stdinContentFieldFlag, "content", fmt.Sprintf("Field of the %s records holding their content", stdinSplitNdjson))
+
+	return command, nil
+}
+
+func (p *StdinPlugin) initialize() error {
+	p.Split = strings.ToLower(p.Split)
+	switch p.Split {
+	case stdinSplitNone, stdinSplitNul, stdinSplitNdjson:
+	default:
+		return fmt.Errorf("invalid split mode: %s, available modes are: %s, %s and %s", p.Split, stdinSplitNone, stdinSplitNul, stdinSplitNdjson)
+	}
+	if p.Input == nil {
+		p.Input = os.Stdin
+	}
+	return nil
+}
+
+func (p *StdinPlugin) getItems() {
+	p.WaitGroup.Add(1)
+	go func() {
+		defer p.WaitGroup.Done()
+
+		var err error
+		switch p.Split {
+		case stdinSplitNone:
+			err = p.readAll()
+		case stdinSplitNul:
+			err = p.readRecords(0, p.getNulItem)
+		case stdinSplitNdjson:
+			err = p.readRecords('\n', p.getNdjsonItem)
+		}
+		if err != nil {
+			p.Errors <- fmt.Errorf("error while reading standard input: %w", err)
+		}
+	}()
+}
+
+func (p *StdinPlugin) readAll() error {
+	content, err := io.ReadAll(p.Input)
+	if err != nil {
+		return err
+	}
+	if len(content) > 0 {
+		p.Items <- Item{Content: string(content), ID: p.DefaultID}
+	}
+	return nil
+}
+
+// readRecords sends an item for each record as soon as it is read, so streams are scanned while they are written.
+// The records are not limited in size, like the input read as a single item.
+func (p *StdinPlugin) readRecords(delimiter byte, getItem func(record []byte, position int) (*Item, error)) error {
+	reader := bufio.NewReader(p.Input)
+
+	for position := 1; ; position++ {
+		record, err := reader.ReadBytes(delimiter)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(record) > 0 && record[len(record)-1] == delimiter {
+			record = record[:len(record)-1]
+		}
+		if delimiter == '\n' {
+			record = bytes.TrimSuffix(record, []byte("\r"))
+		}
+
+		if len(bytes.TrimSpace(record)) > 0 {
+			// a malformed record is skipped, only the read errors stop the scan
+			if item, itemErr := getItem(record, position); itemErr != nil {
+				log.Warn().Msgf("Skipping malformed record: %s", itemErr)
+			} else {
+				p.Items <- *item
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+func (p *StdinPlugin) getRecordId(position int) string {
+	return fmt.Sprintf("%s:%d", p.DefaultID, position)
+}
+
+func (p *StdinPlugin) getNulItem(record []byte, position int) (*Item, error) {
+	return &Item{Content: string(record), ID: p.getRecordId(position)}, nil
+}
+
+func (p *StdinPlugin) getNdjsonItem(record []byte, position int) (*Item, error) {
+	fields := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(record))
+	// numeric IDs are kept as written
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("could not unmarshal record %d: %w", position, err)
+	}
+
+	content, ok := fields[p.ContentField].(string)
+	if !ok {
+		return nil, fmt.Errorf("record %d has no string '%s' field", position, p.ContentField)
+	}
+	item := &Item{Content: content, ID: p.getRecordId(position)}
+	if id, ok := fields[p.IdField]; ok && id != nil {
+		item.ID = fmt.Sprint(id)
+	}
+	return item, nil
+}
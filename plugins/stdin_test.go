@@ -0,0 +1,107 @@
+package plugins
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func scanStdin(t *testing.T, p *StdinPlugin) ([]Item, error) {
+	p.Channels = Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		return items, err
+	default:
+		return items, nil
+	}
+}
+
+func TestStdinGetItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *StdinPlugin
+		expected []Item
+	}{
+		{
+			name:     "no split",
+			plugin:   &StdinPlugin{Split: stdinSplitNone, DefaultID: stdinDefaultId, Input: strings.NewReader("apiVersion: v1\ndata:\n  password: MTIz\n")},
+			expected: []Item{{Content: "apiVersion: v1\ndata:\n  password: MTIz\n", ID: "stdin"}},
+		},
+		{
+			name:   "nul records",
+			plugin: &StdinPlugin{Split: "NUL", DefaultID: "clipboard", Input: strings.NewReader("first\x00\x00second\nline\x00")},
+			expected: []Item{
+				{Content: "first", ID: "clipboard:1"},
+				{Content: "second\nline", ID: "clipboard:3"},
+			},
+		},
+		{
+			name: "ndjson records",
+			plugin: &StdinPlugin{Split: stdinSplitNdjson, DefaultID: stdinDefaultId, IdField: "url", ContentField: "body", Input: strings.NewReader(
+				`{"url": "https://wiki/page", "body": "token=abc"}` + "\r\n\n" + `{"body": "no id"}` + "\n" + `{"url": 12345678901234567890, "body": "numeric id"}`)},
+			expected: []Item{
+				{Content: "token=abc", ID: "https://wiki/page"},
+				{Content: "no id", ID: "stdin:3"},
+				{Content: "numeric id", ID: "12345678901234567890"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := scanStdin(t, test.plugin)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(items) != len(test.expected) {
+				t.Fatalf("expected %d items, but got %d: %v", len(test.expected), len(items), items)
+			}
+			for i, item := range items {
+				if item.Content != test.expected[i].Content || item.ID != test.expected[i].ID {
+					t.Errorf("expected item %v, but got %v", test.expected[i], item)
+				}
+			}
+		})
+	}
+}
+
+func TestStdinLargeRecord(t *testing.T) {
+	large := strings.Repeat("a", maxAttachmentSize+1)
+
+	items, err := scanStdin(t, &StdinPlugin{Split: stdinSplitNul, DefaultID: stdinDefaultId, Input: strings.NewReader(large + "\x00token=abc")})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || len(items[0].Content) != len(large) || items[1].Content != "token=abc" {
+		t.Errorf("expected the large record and the next one, but got %d items", len(items))
+	}
+}
+
+func TestStdinInvalidRecord(t *testing.T) {
+	p := &StdinPlugin{Split: stdinSplitNdjson, DefaultID: stdinDefaultId, IdField: "id", ContentField: "content", Input: strings.NewReader(
+		`{"content": "ok"}` + "\n" + `{"text": "missing content"}` + "\n" + `{"content": "truncated` + "\n" + `{"content": "token=abc"}`)}
+
+	items, err := scanStdin(t, p)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].ID != "stdin:1" || items[1].ID != "stdin:4" {
+		t.Errorf("expected the valid records 1 and 4, but got %v", items)
+	}
+}
@@ -0,0 +1,332 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/checkmarx/2ms/lib"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	teamsTenantIdFlag         = "tenant-id"
+	teamsClientIdFlag         = "client-id"
+	teamsClientSecretFlag     = "client-secret"
+	teamsTeamFlag             = "team"
+	teamsChannelFlag          = "channel"
+	teamsBackwardDurationFlag = "duration"
+	teamsGraphUrlFlag         = "graph-url"
+	teamsTokenUrlFlag         = "token-url"
+)
+
+const (
+	teamsDefaultGraphUrl    = "https://graph.microsoft.com/v1.0"
+	teamsDefaultTokenUrl    = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	teamsDefaultDateFrom    = time.Hour * 24 * 14
+	teamsMessagesPageSize   = 50
+	teamsTeamMetadataKey    = "team"
+	teamsChannelMetadataKey = "channel"
+)
+
+type TeamsPlugin struct {
+	Plugin
+	Channels
+
+	TenantID         string
+	ClientID         string
+	ClientSecret     string
+	Teams            []string
+	ChannelNames     []string
+	BackwardDuration time.Duration
+	GraphURL         string
+	TokenURL         string
+
+	oauth *lib.OAuthClientCredentials
+}
+
+func (p *TeamsPlugin) GetName() string {
+	return "teams"
+}
+
+func (p *TeamsPlugin) GetAuthorizationHeader() (string, error) {
+	token, err := p.oauth.GetToken()
+	if err != nil {
+		return "", fmt.Errorf("error while getting microsoft graph token: %w", err)
+	}
+	return lib.CreateBearerAuthCredentials(token), nil
+}
+
+func (p *TeamsPlugin) DefineCommand(channels Channels) (*cobra.Command, error) {
+	p.Channels = channels
+
+	command := &cobra.Command{
+		Use:   fmt.Sprintf("%s --%s TENANT --%s CLIENT --%s SECRET --%s TEAM", p.GetName(), teamsTenantIdFlag, teamsClientIdFlag, teamsClientSecretFlag, teamsTeamFlag),
+		Short: "Scan Microsoft Teams",
+		Long:  "Scan Microsoft Teams channels messages and replies for sensitive information, using the Microsoft Graph API.",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := p.initialize(); err != nil {
+				p.Errors <- fmt.Errorf("error while initializing teams plugin: %w", err)
+				return
+			}
+			log.Info().Msg("Teams plugin started")
+			p.getItems()
+		},
+	}
+
+	flags := command.Flags()
+	flags.StringVar(&p.TenantID, teamsTenantIdFlag, "", "Microsoft Entra tenant ID [required]")
+	flags.StringVar(&p.ClientID, teamsClientIdFlag, "", "Application (client) ID, the application needs the Team.ReadBasic.All, Channel.ReadBasic.All and ChannelMessage.Read.All permissions [required]")
+	flags.StringVar(&p.ClientSecret, teamsClientSecretFlag, "", "Application client secret [required]")
+	flags.StringArrayVar(&p.Teams, teamsTeamFlag, []string{}, "Teams names or IDs to scan [required]")
+	flags.StringArrayVar(&p.ChannelNames, teamsChannelFlag, []string{}, "Channels names or IDs to scan. If not provided, all the channels of the teams will be scanned")
+	flags.DurationVar(&p.BackwardDuration, teamsBackwardDurationFlag, teamsDefaultDateFrom, "Teams backward duration for messages (ex: 24h, 7d, 1M, 1y)")
+	flags.StringVar(&p.GraphURL, teamsGraphUrlFlag, teamsDefaultGraphUrl, "Microsoft Graph API base URL")
+	flags.StringVar(&p.TokenURL, teamsTokenUrlFlag, "", fmt.Sprintf("OAuth 2.0 token endpoint URL (default %s)", fmt.Sprintf(teamsDefaultTokenUrl, "<tenant-id>")))
+	for _, flag := range []string{teamsTenantIdFlag, teamsClientIdFlag, teamsClientSecretFlag, teamsTeamFlag} {
+		if err := command.MarkFlagRequired(flag); err != nil {
+			return nil, fmt.Errorf("error while marking flag %s as required: %w", flag, err)
+		}
+	}
+
+	return command, nil
+}
+
+func (p *TeamsPlugin) initialize() error {
+	p.GraphURL = strings.TrimRight(p.GraphURL, "/")
+	graphUrl, err := url.Parse(p.GraphURL)
+	if err != nil || graphUrl.Host == "" {
+		return fmt.Errorf("invalid graph url: %s", p.GraphURL)
+	}
+	if p.TokenURL == "" {
+		p.TokenURL = fmt.Sprintf(teamsDefaultTokenUrl, url.PathEscape(p.TenantID))
+	}
+
+	// the client credentials grant requests all the application permissions of the Graph API
+	scope := fmt.Sprintf("%s://%s/.default", graphUrl.Scheme, graphUrl.Host)
+	p.oauth = lib.NewOAuthClientCredentials(p.TokenURL, p.ClientID, p.ClientSecret, []string{scope})
+	if _, err := p.oauth.GetToken(); err != nil {
+		return fmt.Errorf("error while getting microsoft graph token: %w", err)
+	}
+	return nil
+}
+
+func (p *TeamsPlugin) getItems() {
+	teams, err := p.getTeams()
+	if err != nil {
+		p.Errors <- fmt.Errorf("error while getting teams: %w", err)
+		return
+	}
+
+	for _, team := range teams {
+		channels, err := p.getChannels(team)
+		if err != nil {
+			p.Errors <- fmt.Errorf("error while getting channels for team %s: %w", team.DisplayName, err)
+			return
+		}
+		if len(channels) == 0 {
+			log.Warn().Msgf("No channels found for team %s", team.DisplayName)
+			continue
+		}
+
+		log.Info().Msgf("Found %d channels for team %s", len(channels), team.DisplayName)
+		p.WaitGroup.Add(len(channels))
+		for _, channel := range channels {
+			go p.getItemsFromChannel(team, channel)
+		}
+	}
+}
+
+func (p *TeamsPlugin) getTeams() ([]TeamsTeam, error) {
+	selectedTeams := []TeamsTeam{}
+	found := map[string]bool{}
+	err := getGraphResults(p, p.GraphURL+"/teams", func(teams []TeamsTeam) error {
+		for _, team := range teams {
+			for _, wanted := range p.Teams {
+				if !found[wanted] && (team.DisplayName == wanted || team.ID == wanted) {
+					found[wanted] = true
+					selectedTeams = append(selectedTeams, team)
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, wanted := range p.Teams {
+		if !found[wanted] {
+			return nil, fmt.Errorf("team '%s' not found", wanted)
+		}
+	}
+	return selectedTeams, nil
+}
+
+func (p *TeamsPlugin) getChannels(team TeamsTeam) ([]TeamsChannel, error) {
+	selectedChannels := []TeamsChannel{}
+	err := getGraphResults(p, fmt.Sprintf("%s/teams/%s/channels", p.GraphURL, team.ID), func(channels []TeamsChannel) error {
+		for _, channel := range channels {
+			if len(p.ChannelNames) == 0 {
+				selectedChannels = append(selectedChannels, channel)
+				continue
+			}
+			for _, wanted := range p.ChannelNames {
+				if channel.DisplayName == wanted || channel.ID == wanted {
+					selectedChannels = append(selectedChannels, channel)
+					break
+				}
+			}
+		}
+		return nil
+	})
+	return selectedChannels, err
+}
+
+func (p *TeamsPlugin) getItemsFromChannel(team TeamsTeam, channel TeamsChannel) {
+	defer p.WaitGroup.Done()
+	log.Info().Msgf("Getting items from channel %s", channel.DisplayName)
+
+	since := timeNow.Add(-p.BackwardDuration)
+	messagesUrl := fmt.Sprintf("%s/teams/%s/channels/%s/messages?$top=%d", p.GraphURL, team.ID, channel.ID, teamsMessagesPageSize)
+	err := getGraphResults(p, messagesUrl, func(messages []TeamsMessage) error {
+		// the messages are not sorted by modification date, an old thread with a new reply may be on any page
+		for _, message := range messages {
+			if p.BackwardDuration != 0 && message.LastModifiedDateTime.Before(since) {
+				continue
+			}
+			p.sendMessageItem(team, channel, message)
+
+			repliesUrl := fmt.Sprintf("%s/teams/%s/channels/%s/messages/%s/replies?$top=%d", p.GraphURL, team.ID, channel.ID, message.ID, teamsMessagesPageSize)
+			err := getGraphResults(p, repliesUrl, func(replies []TeamsMessage) error {
+				for _, reply := range replies {
+					p.sendMessageItem(team, channel, reply)
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("error while getting replies for message %s: %w", message.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		p.Errors <- fmt.Errorf("error while getting messages for channel %s: %w", channel.DisplayName, err)
+	}
+}
+
+func (p *TeamsPlugin) sendMessageItem(team TeamsTeam, channel TeamsChannel, message TeamsMessage) {
+	if message.DeletedDateTime != nil {
+		return
+	}
+
+	content := getTeamsMessageContent(message)
+	if content == "" {
+		return
+	}
+
+	id := message.WebUrl
+	if id == "" {
+		id = fmt.Sprintf("Team: %s; Channel: %s; Message: %s", team.DisplayName, channel.DisplayName, message.ID)
+	}
+	p.Items <- Item{
+		Content:  content,
+		ID:       id,
+		Metadata: map[string]string{teamsTeamMetadataKey: team.DisplayName, teamsChannelMetadataKey: channel.DisplayName},
+	}
+}
+
+// getTeamsMessageContent returns the text of a message with its subject and the content of its attachments (cards, quoted messages)
+func getTeamsMessageContent(message TeamsMessage) string {
+	texts := []string{message.Subject}
+
+	body := message.Body.Content
+	if strings.EqualFold(message.Body.ContentType, "html") {
+		if text, _, err := convertHtmlToText(body); err == nil {
+			body = text
+		}
+	}
+	texts = append(texts, body)
+
+	for _, attachment := range message.Attachments {
+		texts = append(texts, attachment.Name, attachment.ContentUrl, attachment.Content)
+	}
+
+	contents := []string{}
+	for _, text := range texts {
+		if strings.TrimSpace(text) != "" {
+			contents = append(contents, text)
+		}
+	}
+	return strings.Join(contents, "\n")
+}
+
+// getGraphResults requests the given url and then follows the next links returned by the Graph API,
+// calling handle with the results of each page
+func getGraphResults[T any](p *TeamsPlugin, url string, handle func(results []T) error) error {
+	for url != "" {
+		body, err := p.graphRequest(url)
+		if err != nil {
+			return err
+		}
+
+		response := GraphResults[T]{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return fmt.Errorf("could not unmarshal response %w", err)
+		}
+
+		if err := handle(response.Value); err != nil {
+			return err
+		}
+		url = response.NextLink
+	}
+	return nil
+}
+
+// graphRequest retries the requests throttled by the Graph API after the Retry-After delay
+// https://learn.microsoft.com/en-us/graph/throttling
+func (p *TeamsPlugin) graphRequest(url string) ([]byte, error) {
+	body, _, err := lib.RetryHttpRequest(func() ([]byte, *http.Response, error) {
+		return lib.HttpRequest(http.MethodGet, url, p)
+	}, lib.RetryAfterDelay)
+	return body, err
+}
+
+// GraphResults is a page of results returned by the Microsoft Graph API
+type GraphResults[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"@odata.nextLink"`
+}
+
+type TeamsTeam struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type TeamsChannel struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+type TeamsMessage struct {
+	ID                   string     `json:"id"`
+	Subject              string     `json:"subject"`
+	WebUrl               string     `json:"webUrl"`
+	CreatedDateTime      time.Time  `json:"createdDateTime"`
+	LastModifiedDateTime time.Time  `json:"lastModifiedDateTime"`
+	DeletedDateTime      *time.Time `json:"deletedDateTime"`
+	Body                 struct {
+		ContentType string `json:"contentType"`
+		Content     string `json:"content"`
+	} `json:"body"`
+	Attachments []struct {
+		Name       string `json:"name"`
+		ContentUrl string `json:"contentUrl"`
+		Content    string `json:"content"`
+	} `json:"attachments"`
+}
@@ -0,0 +1,182 @@
+package plugins
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetTeamsMessageContent(t *testing.T) {
+	message := TeamsMessage{Subject: "Deploy"}
+	message.Body.ContentType = "html"
+	message.Body.Content = "<p>the <b>password</b> is 123</p>"
+
+	content := getTeamsMessageContent(message)
+
+	expected := "Deploy\nthe password is 123"
+	if content != expected {
+		t.Errorf("expected content %q, but got %q", expected, content)
+	}
+}
+
+func newFakeGraphApi(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	throttled := false
+	message := func(id string, content string, modified time.Time) map[string]interface{} {
+		return map[string]interface{}{
+			"id":                   id,
+			"webUrl":               "https://teams.microsoft.com/l/message/" + id,
+			"lastModifiedDateTime": modified.Format(time.RFC3339),
+			"body":                 map[string]string{"contentType": "html", "content": content},
+		}
+	}
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth2/v2.0/token" {
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if scope := r.PostForm.Get("scope"); scope != server.URL+"/.default" {
+				t.Errorf("unexpected scope %s", scope)
+			}
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var response interface{}
+		switch r.URL.Path {
+		case "/v1.0/teams":
+			if !throttled {
+				throttled = true
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			if r.URL.Query().Get("page") == "" {
+				response = map[string]interface{}{
+					"value":           []map[string]string{{"id": "team-1", "displayName": "Marketing"}},
+					"@odata.nextLink": server.URL + "/v1.0/teams?page=2",
+				}
+			} else {
+				response = map[string]interface{}{"value": []map[string]string{{"id": "team-2", "displayName": "Ops"}}}
+			}
+		case "/v1.0/teams/team-2/channels":
+			response = map[string]interface{}{"value": []map[string]string{
+				{"id": "channel-1", "displayName": "General"},
+				{"id": "channel-2", "displayName": "Random"},
+			}}
+		case "/v1.0/teams/team-2/channels/channel-1/messages":
+			switch r.URL.Query().Get("page") {
+			case "":
+				response = map[string]interface{}{
+					"value":           []interface{}{message("1", "<p>password=123</p>", timeNow), message("2", "hello", timeNow.Add(-time.Hour))},
+					"@odata.nextLink": server.URL + "/v1.0/teams/team-2/channels/channel-1/messages?page=2",
+				}
+			case "2":
+				response = map[string]interface{}{
+					"value":           []interface{}{message("3", "too old", timeNow.Add(-time.Hour*48))},
+					"@odata.nextLink": server.URL + "/v1.0/teams/team-2/channels/channel-1/messages?page=3",
+				}
+			default:
+				// an old thread with a recent reply
+				response = map[string]interface{}{"value": []interface{}{message("5", "secret=xyz", timeNow)}}
+			}
+		case "/v1.0/teams/team-2/channels/channel-1/messages/1/replies":
+			response = map[string]interface{}{"value": []interface{}{message("4", "token=abc", timeNow)}}
+		case "/v1.0/teams/team-2/channels/channel-1/messages/2/replies", "/v1.0/teams/team-2/channels/channel-1/messages/5/replies":
+			response = map[string]interface{}{"value": []interface{}{}}
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("error while encoding response: %v", err)
+		}
+	}))
+	return server
+}
+
+func TestTeamsGetItems(t *testing.T) {
+	server := newFakeGraphApi(t)
+	defer server.Close()
+
+	p := &TeamsPlugin{
+		Channels:         Channels{Items: make(chan Item), Errors: make(chan error, 1), WaitGroup: &sync.WaitGroup{}},
+		TenantID:         "tenant",
+		ClientID:         "client",
+		ClientSecret:     "secret",
+		Teams:            []string{"team-2"},
+		ChannelNames:     []string{"General"},
+		BackwardDuration: time.Hour * 24,
+		GraphURL:         server.URL + "/v1.0/",
+		TokenURL:         server.URL + "/oauth2/v2.0/token",
+	}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	p.getItems()
+	go func() {
+		p.WaitGroup.Wait()
+		close(p.Items)
+	}()
+
+	items := []Item{}
+	for item := range p.Items {
+		items = append(items, item)
+	}
+	select {
+	case err := <-p.Errors:
+		t.Fatalf("unexpected error: %v", err)
+	default:
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	expected := []Item{
+		{Content: "password=123", ID: "https://teams.microsoft.com/l/message/1"},
+		{Content: "hello", ID: "https://teams.microsoft.com/l/message/2"},
+		{Content: "token=abc", ID: "https://teams.microsoft.com/l/message/4"},
+		{Content: "secret=xyz", ID: "https://teams.microsoft.com/l/message/5"},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d items, but got %d: %v", len(expected), len(items), items)
+	}
+	for i, item := range items {
+		if item.Content != expected[i].Content || item.ID != expected[i].ID {
+			t.Errorf("expected item %v, but got %v", expected[i], item)
+		}
+		if item.Metadata[teamsTeamMetadataKey] != "Ops" || item.Metadata[teamsChannelMetadataKey] != "General" {
+			t.Errorf("unexpected metadata %v", item.Metadata)
+		}
+	}
+}
+
+func TestTeamsTeamNotFound(t *testing.T) {
+	server := newFakeGraphApi(t)
+	defer server.Close()
+
+	p := &TeamsPlugin{
+		ClientSecret: "secret",
+		Teams:        []string{"Ops", "Sales"},
+		GraphURL:     server.URL + "/v1.0",
+		TokenURL:     server.URL + "/oauth2/v2.0/token",
+	}
+	if err := p.initialize(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err := p.getTeams()
+	if err == nil || err.Error() != "team 'Sales' not found" {
+		t.Errorf("expected team not found error, but got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package plugins
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Elements of HTML and of the Confluence storage format that end a line of text
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "li": true, "tr": true, "pre": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "table": true,
+	"structured-macro": true, "parameter": true, "plain-text-body": true, "rich-text-body": true,
+	"task": true, "layout-section": true, "layout-cell": true,
+}
+
+// Elements that separate cells of a line of text
+var htmlCellElements = map[string]bool{
+	"td": true, "th": true,
+}
+
+// Elements whose text is kept as is
+var htmlPreformattedElements = map[string]bool{
+	"pre": true, "plain-text-body": true,
+}
+
+// Attributes holding link targets, which may embed credentials
+var htmlLinkAttributes = map[string]bool{
+	"href": true, "value": true,
+}
+
+type htmlTextWriter struct {
+	text        strings.Builder
+	lines       []int
+	lineStarted bool
+}
+
+func (w *htmlTextWriter) write(text string, sourceLine int) {
+	for i, part := range strings.Split(text, "\n") {
+		if i > 0 {
+			w.newLine()
+		}
+		if part == "" {
+			continue
+		}
+		if !w.lineStarted {
+			w.lines[len(w.lines)-1] = sourceLine + i
+			w.lineStarted = true
+		}
+		w.text.WriteString(part)
+	}
+}
+
+func (w *htmlTextWriter) newLine() {
+	w.text.WriteString("\n")
+	w.lines = append(w.lines, w.lines[len(w.lines)-1])
+	w.lineStarted = false
+}
+
+func (w *htmlTextWriter) endBlock() {
+	if w.lineStarted {
+		w.newLine()
+	}
+}
+
+// convertHtmlToText converts HTML, like the Confluence storage format (XHTML), to plain text, so secrets are not split by
+// markup or corrupted by HTML entities. The content of code macros and the targets of links are kept.
+// It returns the text and the original line (0-based) of each line of text.
+func convertHtmlToText(html string) (string, []int, error) {
+	decoder := xml.NewDecoder(strings.NewReader("<html>" + html + "</html>"))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+
+	writer := &htmlTextWriter{lines: []int{0}}
+	preformatted := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		line, _ := decoder.InputPos()
+		line-- // 0-based, as the lines reported by the detection
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			if htmlBlockElements[element.Name.Local] {
+				writer.endBlock()
+			}
+			if htmlPreformattedElements[element.Name.Local] {
+				preformatted++
+			}
+			if htmlCellElements[element.Name.Local] && writer.lineStarted {
+				writer.write(" ", line)
+			}
+			for _, attribute := range element.Attr {
+				if htmlLinkAttributes[attribute.Name.Local] && attribute.Value != "" {
+					writer.write(attribute.Value+" ", line)
+				}
+			}
+		case xml.EndElement:
+			if htmlBlockElements[element.Name.Local] {
+				writer.endBlock()
+			}
+			if htmlPreformattedElements[element.Name.Local] && preformatted > 0 {
+				preformatted--
+			}
+		case xml.CharData:
+			text := strings.ReplaceAll(string(element), "\u00a0", " ")
+			sourceLine := line - strings.Count(text, "\n")
+			if preformatted == 0 {
+				// line breaks of the markup are not part of the text
+				if strings.TrimSpace(text) == "" {
+					continue
+				}
+				text = strings.ReplaceAll(text, "\n", " ")
+			}
+			writer.write(text, sourceLine)
+		}
+	}
+
+	text := strings.TrimSuffix(writer.text.String(), "\n")
+	return text, writer.lines[:strings.Count(text, "\n")+1], nil
+}
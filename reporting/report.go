@@ -20,15 +20,17 @@ type Report struct {
 	Results           map[string][]Secret `json:"results"`
 }
 
+// StartColumn and EndColumn are 0 when unknown, e.g. for content converted from XHTML
 type Secret struct {
-	ID          string `json:"id"`
-	Source      string `json:"source"`
-	Description string `json:"description"`
-	StartLine   int    `json:"startLine"`
-	EndLine     int    `json:"endLine"`
-	StartColumn int    `json:"startColumn"`
-	EndColumn   int    `json:"endColumn"`
-	Value       string `json:"value"`
+	ID          string            `json:"id" yaml:"id"`
+	Source      string            `json:"source" yaml:"source"`
+	Description string            `json:"description" yaml:"description"`
+	StartLine   int               `json:"startLine" yaml:"startline"`
+	EndLine     int               `json:"endLine" yaml:"endline"`
+	StartColumn int               `json:"startColumn" yaml:"startcolumn"`
+	EndColumn   int               `json:"endColumn" yaml:"endcolumn"`
+	Value       string            `json:"value" yaml:"value"`
+	Metadata    map[string]string `json:"metadata,omitempty" yaml:"metadata,omitempty"`
 }
 
 func Init() *Report {
@@ -65,8 +65,9 @@ func getResults(report Report) []Results {
 				Message: Message{
 					Text: messageText(secret),
 				},
-				RuleId:    secret.Description,
-				Locations: getLocation(secret),
+				RuleId:     secret.Description,
+				Locations:  getLocation(secret),
+				Properties: secret.Metadata,
 			}
 			results = append(results, r)
 		}
@@ -123,9 +124,9 @@ type ArtifactLocation struct {
 
 type Region struct {
 	StartLine   int     `json:"startLine"`
-	StartColumn int     `json:"startColumn"`
+	StartColumn int     `json:"startColumn,omitempty"`
 	EndLine     int     `json:"endLine"`
-	EndColumn   int     `json:"endColumn"`
+	EndColumn   int     `json:"endColumn,omitempty"`
 	Snippet     Snippet `json:"snippet"`
 }
 
@@ -143,9 +144,10 @@ type Locations struct {
 }
 
 type Results struct {
-	Message   Message     `json:"message"`
-	RuleId    string      `json:"ruleId"`
-	Locations []Locations `json:"locations"`
+	Message    Message           `json:"message"`
+	RuleId     string            `json:"ruleId"`
+	Locations  []Locations       `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
 }
 
 type Runs struct {
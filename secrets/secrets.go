@@ -66,8 +66,22 @@ func (s *Secrets) Detect(secretsChannel chan reporting.Secret, item plugins.Item
 	}
 	for _, value := range s.detector.Detect(fragment) {
 		itemId := getItemId(item.ID)
-		secretsChannel <- reporting.Secret{ID: itemId, Source: item.ID, Description: value.Description, StartLine: value.StartLine, StartColumn: value.StartColumn, EndLine: value.EndLine, EndColumn: value.EndColumn, Value: value.Secret}
+		startLine := getOriginalLine(item, value.StartLine)
+		endLine := getOriginalLine(item, value.EndLine)
+		startColumn, endColumn := value.StartColumn, value.EndColumn
+		// The columns of converted content don't match the original, they are left out of the report
+		if item.LineMapping != nil {
+			startColumn, endColumn = 0, 0
+		}
+		secretsChannel <- reporting.Secret{ID: itemId, Source: item.ID, Description: value.Description, StartLine: startLine, StartColumn: startColumn, EndLine: endLine, EndColumn: endColumn, Value: value.Secret, Metadata: item.Metadata}
+	}
+}
+
+func getOriginalLine(item plugins.Item, line int) int {
+	if line >= 0 && line < len(item.LineMapping) {
+		return item.LineMapping[line]
 	}
+	return line
 }
 
 func getItemId(fullPath string) string {